@@ -1,13 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
 	"time"
 
 	"github.com/backmarket-oss/raccoon/internal"
-	"github.com/backmarket-oss/raccoon/internal/k8s"
 	"github.com/backmarket-oss/raccoon/internal/strategy"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -22,6 +21,13 @@ var (
 			if err != nil {
 				return fmt.Errorf("error providing a strategy: %v", err)
 			}
+			adminAddress, err := cmd.Flags().GetString("admin-address")
+			if err != nil {
+				return err
+			}
+			if adminAddress != "" {
+				serveAdmin(cmd.Context(), adminAddress, strategy.ResetHandler())
+			}
 			interval, err := cmd.Flags().GetInt("check-interval")
 			if err != nil {
 				return err
@@ -34,55 +40,121 @@ var (
 
 func init() {
 	defaultSettings = &internal.DefaultSettings{}
-	homedir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatal(err)
-	}
 
 	rootCmd.AddCommand(garbageCmd)
 	// required flags
 
 	// optional flags
-	garbageCmd.Flags().StringVarP(&defaultSettings.Namespace, "namespace", "n", "", "Namespace to raccoon")
-	garbageCmd.Flags().StringVarP(&defaultSettings.Selector, "selector", "s", "backmarket.com/raccoon=true",
-		"Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
-	garbageCmd.Flags().String("kube-location", "in", "Connection mode to the kubernetes api (in or out)")
-	garbageCmd.Flags().DurationVar(&defaultSettings.TTL, "ttl", 24*time.Hour, "Minimum age by which a pod will be deleted")
+	addSelectionFlags(garbageCmd, defaultSettings)
 	garbageCmd.Flags().Int("check-interval", 120, "Interval between two raccoon check")
 	garbageCmd.Flags().Int("randomized-delay", 120, "Delay the deletion by a randomly amount of time [value/2,value]")
 	garbageCmd.Flags().BoolVar(&defaultSettings.DryRun, "dry-run", false, "Test process without deletion")
-	garbageCmd.Flags().String("kubeconfig", filepath.Join(homedir, ".kube", "config"), "Path to KUBECONFIG file. Ignored if KUBECONFIG envvar is set")
+	garbageCmd.Flags().StringVar(&defaultSettings.Action, "action", internal.ActionEvict,
+		"Action applied on pods older than the ttl (evict or rollout-restart)")
+	garbageCmd.Flags().DurationVar(&defaultSettings.RestartCooldown, "restart-cooldown", time.Hour,
+		"Minimum duration between two rollout restarts of the same workload")
+	garbageCmd.Flags().IntVar(&defaultSettings.MinReadyReplicas, "min-ready-replicas", 1,
+		"Minimum number of ready replicas a workload must keep after an eviction, 0 to disable")
+	garbageCmd.Flags().Float64Var(&defaultSettings.MaxUnhealthyRatio, "max-unhealthy-ratio", 0,
+		"Ratio of not ready pods in a namespace or a workload above which collection is suspended there, 0 to disable")
+	garbageCmd.Flags().IntVar(&defaultSettings.BreakerFailures, "breaker-failures", 0,
+		"Number of evicted pods' replacements failing to become ready which stops collection, 0 to disable")
+	garbageCmd.Flags().DurationVar(&defaultSettings.BreakerDeadline, "breaker-deadline", 10*time.Minute,
+		"Duration given to an evicted pod's replacement to become ready")
+	garbageCmd.Flags().DurationVar(&defaultSettings.BreakerCooldown, "breaker-cooldown", time.Hour,
+		"Duration after which collection resumes once stopped by replacement failures")
+	garbageCmd.Flags().DurationVar(&defaultSettings.EvictionBackoff, "eviction-backoff", 5*time.Minute,
+		"Initial delay before retrying an eviction refused by a disruption budget, doubled at each attempt up to 1h")
+	garbageCmd.Flags().DurationVar(&defaultSettings.MaxLateness, "max-lateness", 0,
+		"Duration past the ttl after which a blocked eviction is escalated with a warning event, 0 to disable")
+	garbageCmd.Flags().BoolVar(&defaultSettings.EscalationDelete, "escalation-delete", false,
+		"Delete pods whose blocked eviction is escalated, bypassing their disruption budget")
+	garbageCmd.Flags().DurationVar(&defaultSettings.EscalationGracePeriod, "escalation-grace-period", 30*time.Second,
+		"Grace period given to pods deleted on escalation")
+	garbageCmd.Flags().DurationVar(&defaultSettings.TopologyWindow, "topology-window", 0,
+		"Minimum duration between two collections on the same node or in the same zone, 0 to disable")
+	garbageCmd.Flags().IntVar(&defaultSettings.MaxZoneDisruptions, "max-zone-disruptions", 0,
+		"Maximum number of not ready or terminating pods per zone above which no pod is collected there, 0 to disable")
+	garbageCmd.Flags().IntVar(&defaultSettings.SurgeMaxReplicas, "surge-max-replicas", 0,
+		"Deployments with up to this number of replicas are scaled up by one before evicting a pod, 0 to disable")
+	garbageCmd.Flags().DurationVar(&defaultSettings.SurgeTimeout, "surge-timeout", 5*time.Minute,
+		"Duration given to the extra pod of a surged Deployment to become ready")
+	garbageCmd.Flags().BoolVar(&defaultSettings.SkipUnstableWorkloads, "skip-unstable-workloads", false,
+		"Defer collection in workloads whose rollout is in progress or whose autoscaler is at its max replicas")
+	garbageCmd.Flags().DurationVar(&defaultSettings.DrainPeriod, "drain-period", 30*time.Second,
+		"Duration waited for between draining a pod declaring the drain readiness gate and evicting it")
+	garbageCmd.Flags().DurationVar(&defaultSettings.PreEvictHookTimeout, "pre-evict-hook-timeout", 30*time.Second,
+		"Maximum duration waited for a pod's pre-evict hook to respond before evicting it")
+	garbageCmd.Flags().DurationVar(&defaultSettings.EvictionNotice, "eviction-notice", 0,
+		"Duration before their expiry at which pods are annotated with the date they are collected at, 0 to disable")
+	garbageCmd.Flags().BoolVar(&defaultSettings.CollectStaleTemplates, "collect-stale-templates", false,
+		"Collect pods whose revision or images differ from their owner's current template, whatever their age")
+	garbageCmd.Flags().BoolVar(&defaultSettings.CollectConfigChanges, "collect-config-changes", false,
+		"Collect pods whose referenced ConfigMaps or Secrets changed after they started, whatever their age")
+	garbageCmd.Flags().IntVar(&defaultSettings.MaxRestarts, "max-restarts", 0,
+		"Collect pods whose containers restarted at least this number of times, whatever their age, 0 to disable")
+	garbageCmd.Flags().DurationVar(&defaultSettings.OOMKillWindow, "oom-kill-window", 0,
+		"Collect pods with a container OOM killed during this window, whatever their age, 0 to disable")
+	garbageCmd.Flags().Float64Var(&defaultSettings.MemoryLimitRatio, "memory-limit-ratio", 0,
+		"Collect pods with a container using more than this ratio of its memory limit for the sustain period, 0 to disable")
+	garbageCmd.Flags().DurationVar(&defaultSettings.MemorySustainPeriod, "memory-sustain-period", 30*time.Minute,
+		"Duration a container must stay above the memory limit ratio for its pod to be collected")
+	garbageCmd.Flags().String("pause-configmap", "",
+		"ConfigMap (namespace/name) acting as a kill switch, pausing raccoon when its 'paused' key is 'true'")
+	garbageCmd.Flags().String("admin-address", "",
+		"Address of the admin listener serving /circuits/reset (e.g. 127.0.0.1:2113), kept apart from metrics, "+
+			"empty to disable")
+	addKubeFlags(garbageCmd)
+}
+
+// serveAdmin serves the admin endpoints, which act on the daemon, on their own listener until the context is done.
+func serveAdmin(ctx context.Context, address string, reset http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/circuits/reset", reset)
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		log.Debugf("admin HTTP server starting and listening on %s", address)
+
+		// nosemgrep: go.lang.security.audit.net.use-tls.use-tls
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("error starting admin http server: %s\n", err)
+		}
+	}()
 }
 
-func provideStrategy(cmd *cobra.Command) (internal.Strategy, error) {
+func provideStrategy(cmd *cobra.Command) (*strategy.RandomizedDelay, error) {
+	if err := defaultSettings.Validate(); err != nil {
+		return nil, err
+	}
 	maxDelay, err := cmd.Flags().GetInt("randomized-delay")
 	if err != nil {
 		return nil, err
 	}
-	k8sLocation, err := cmd.Flags().GetString("kube-location")
+	k8sClient, err := provideKubernetesClient(cmd)
 	if err != nil {
 		return nil, err
 	}
-
-	kubeConfig := os.Getenv("KUBECONFIG")
-	// If no KUBECONFIG environment variable and we are executing out of the cluster
-	if kubeConfig == "" && k8sLocation == "out" {
-		kubeConfig, err = cmd.Flags().GetString("kubeconfig")
-		if err != nil {
+	if defaultSettings.MemoryLimitRatio > 0 {
+		if err := provideMetricsClient(cmd, k8sClient); err != nil {
 			return nil, err
 		}
-		_, err = os.Stat(kubeConfig)
-		if err != nil {
-			return nil, fmt.Errorf("The kubeconfig path you given: %v doesn't exist", kubeConfig)
+	}
+	if defaultSettings.CollectConfigChanges {
+		if err := provideMetadataClient(cmd, k8sClient); err != nil {
+			return nil, err
 		}
-
 	}
-
-	k8sClientSet, err := k8s.AuthenticateToCluster(k8sLocation, kubeConfig)
+	pauseConfigMap, err := cmd.Flags().GetString("pause-configmap")
 	if err != nil {
 		return nil, err
 	}
-	k8sClient := k8s.InitKubernetesClient(k8sClientSet)
+	if err := k8sClient.WatchPause(cmd.Context(), pauseConfigMap); err != nil {
+		return nil, err
+	}
 	rndDelayStg := strategy.InitRandomizedDelay(cmd.Context(), maxDelay, defaultSettings, k8sClient)
 	return rndDelayStg, nil
 }
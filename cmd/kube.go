@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// addKubeFlags adds the flags used to connect to the kubernetes api.
+func addKubeFlags(cmd *cobra.Command) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cmd.Flags().String("kube-location", "in", "Connection mode to the kubernetes api (in or out)")
+	cmd.Flags().String("kubeconfig", filepath.Join(homedir, ".kube", "config"), "Path to KUBECONFIG file. Ignored if KUBECONFIG envvar is set")
+}
+
+// provideKubernetesClient connects to the kubernetes api based on the flags added by addKubeFlags.
+func provideKubernetesClient(cmd *cobra.Command) (*k8s.KubernetesClient, error) {
+	k8sLocation, kubeConfig, err := kubeConnection(cmd)
+	if err != nil {
+		return nil, err
+	}
+	k8sClientSet, err := k8s.AuthenticateToCluster(k8sLocation, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return k8s.InitKubernetesClient(k8sClientSet), nil
+}
+
+// provideMetricsClient connects the kubernetes client to the metrics.k8s.io api, only needed to read pods' usage.
+func provideMetricsClient(cmd *cobra.Command, k8sClient *k8s.KubernetesClient) error {
+	k8sLocation, kubeConfig, err := kubeConnection(cmd)
+	if err != nil {
+		return err
+	}
+	metricsClient, err := k8s.MetricsClientForCluster(k8sLocation, kubeConfig)
+	if err != nil {
+		return err
+	}
+	k8sClient.UseMetrics(metricsClient)
+	return nil
+}
+
+// provideMetadataClient connects the kubernetes client to the api reading objects' metadata only, needed to check
+// ConfigMaps and Secrets without reading their data.
+func provideMetadataClient(cmd *cobra.Command, k8sClient *k8s.KubernetesClient) error {
+	k8sLocation, kubeConfig, err := kubeConnection(cmd)
+	if err != nil {
+		return err
+	}
+	metadataClient, err := k8s.MetadataClientForCluster(k8sLocation, kubeConfig)
+	if err != nil {
+		return err
+	}
+	k8sClient.UseMetadata(metadataClient)
+	return nil
+}
+
+// kubeConnection returns the connection mode and the kubeconfig path given by the flags added by addKubeFlags.
+func kubeConnection(cmd *cobra.Command) (string, string, error) {
+	k8sLocation, err := cmd.Flags().GetString("kube-location")
+	if err != nil {
+		return "", "", err
+	}
+
+	kubeConfig := os.Getenv("KUBECONFIG")
+	// If no KUBECONFIG environment variable and we are executing out of the cluster
+	if kubeConfig == "" && k8sLocation == "out" {
+		kubeConfig, err = cmd.Flags().GetString("kubeconfig")
+		if err != nil {
+			return "", "", err
+		}
+		_, err = os.Stat(kubeConfig)
+		if err != nil {
+			return "", "", fmt.Errorf("The kubeconfig path you given: %v doesn't exist", kubeConfig)
+		}
+
+	}
+	return k8sLocation, kubeConfig, nil
+}
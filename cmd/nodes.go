@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/strategy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nodesCmd = &cobra.Command{
+		Use:   "nodes",
+		Short: "Run raccoon daemon recycling nodes older than their ttl",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := nodeSettings.Validate(); err != nil {
+				return err
+			}
+			k8sClient, err := provideKubernetesClient(cmd)
+			if err != nil {
+				return err
+			}
+			pauseConfigMap, err := cmd.Flags().GetString("pause-configmap")
+			if err != nil {
+				return err
+			}
+			if err := k8sClient.WatchPause(cmd.Context(), pauseConfigMap); err != nil {
+				return err
+			}
+			interval, err := cmd.Flags().GetInt("check-interval")
+			if err != nil {
+				return err
+			}
+			return internal.RunDaemon(interval, cmd.Context(), strategy.InitNodeRecycler(nodeSettings, k8sClient))
+		},
+	}
+	nodeSettings *internal.NodeSettings
+)
+
+func init() {
+	nodeSettings = &internal.NodeSettings{}
+
+	rootCmd.AddCommand(nodesCmd)
+	nodesCmd.Flags().StringVarP(&nodeSettings.Selector, "selector", "s", "backmarket.com/raccoon=true",
+		"Selector (label query) to filter nodes on (e.g. -s key1=value1,key2=value2)")
+	nodesCmd.Flags().DurationVar(&nodeSettings.TTL, "ttl", 7*24*time.Hour, "Minimum age by which a node will be recycled")
+	nodesCmd.Flags().Int("check-interval", 120, "Interval between two raccoon check")
+	nodesCmd.Flags().BoolVar(&nodeSettings.DryRun, "dry-run", false, "Test process without cordon, eviction nor deletion")
+	nodesCmd.Flags().BoolVar(&nodeSettings.DeleteNodes, "delete-nodes", false,
+		"Delete drained nodes, so the cluster autoscaler replaces them")
+	nodesCmd.Flags().IntVar(&nodeSettings.MaxConcurrentNodes, "max-concurrent-nodes", 1,
+		"Maximum number of nodes cordoned by raccoon at the same time, drained or not")
+	nodesCmd.Flags().BoolVar(&nodeSettings.Exclusions.BarePods, "skip-bare-pods", true,
+		"Never evict pods without a controller, as they wouldn't be recreated, they keep their node from being drained")
+	nodesCmd.Flags().BoolVar(&nodeSettings.Exclusions.JobPods, "skip-job-pods", false,
+		"Never evict pods owned by a Job, they keep their node from being drained")
+	nodesCmd.Flags().BoolVar(&nodeSettings.Exclusions.LocalStorage, "skip-local-storage", true,
+		"Never evict pods with an emptyDir volume, as its data would be lost, they keep their node from being drained")
+	nodesCmd.Flags().String("pause-configmap", "",
+		"ConfigMap (namespace/name) acting as a kill switch, pausing raccoon when its 'paused' key is 'true'")
+	addKubeFlags(nodesCmd)
+}
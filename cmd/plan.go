@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/strategy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Show the pods raccoon would collect, in order, without collecting them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := planSettings.Validate(); err != nil {
+				return err
+			}
+			k8sClient, err := provideKubernetesClient(cmd)
+			if err != nil {
+				return err
+			}
+			planned, err := strategy.Plan(cmd.Context(), planSettings, k8sClient)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAMESPACE\tPOD\tAGE\tTTL\tEXPIRED\tPRIORITY\tQOS\tRESTARTS\tSCORE\tSKIPPED")
+			for _, p := range planned {
+				skipped := p.Skipped
+				if skipped == "" {
+					skipped = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%t\t%d\t%s\t%d\t%.3f\t%s\n", p.Namespace, p.Name,
+					p.Age.Truncate(time.Second), p.TTL.Truncate(time.Second), p.Expired, p.Priority, p.QoS,
+					p.Restarts, p.Score, skipped)
+			}
+			return w.Flush()
+		},
+	}
+	planSettings *internal.DefaultSettings
+)
+
+func init() {
+	planSettings = &internal.DefaultSettings{Action: internal.ActionEvict}
+
+	rootCmd.AddCommand(planCmd)
+	addSelectionFlags(planCmd, planSettings)
+	addKubeFlags(planCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/spf13/cobra"
+)
+
+// addSelectionFlags adds the flags selecting the pods to collect and ordering their collection.
+func addSelectionFlags(cmd *cobra.Command, settings *internal.DefaultSettings) {
+	cmd.Flags().StringVarP(&settings.Namespace, "namespace", "n", "", "Namespace to raccoon")
+	cmd.Flags().StringVarP(&settings.Selector, "selector", "s", "backmarket.com/raccoon=true",
+		"Selector (label query) to filter on, supports '=', '==', '!=', 'in', 'notin' and 'exists'."+
+			"(e.g. -s 'key1=value1,key2 in (value2,value3)')")
+	cmd.Flags().StringVar(&settings.FieldSelector, "field-selector", "",
+		"Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector status.phase=Running)")
+	cmd.Flags().StringVar(&settings.ExcludeSelector, "exclude-selector", "",
+		"Selector (label query) of the selected pods to leave out, along with the exclude field selector")
+	cmd.Flags().StringVar(&settings.ExcludeFieldSelector, "exclude-field-selector", "",
+		"Selector (field query) of the selected pods to leave out, along with the exclude selector")
+	cmd.Flags().DurationVar(&settings.TTL, "ttl", 24*time.Hour, "Minimum age by which a pod will be deleted")
+	cmd.Flags().StringVar(&settings.AgeSource, "age-source", k8s.AgeSourceCreation,
+		"Reference from which a pod's age is measured (creation, startTime or oldest-container-start)")
+	cmd.Flags().Float64Var(&settings.Score.Age, "score-age-weight", 0,
+		"Weight of the pod's age over its ttl in the score ordering collection")
+	cmd.Flags().Float64Var(&settings.Score.Priority, "score-priority-weight", 0,
+		"Weight of the pod's low priority in the score ordering collection")
+	cmd.Flags().Float64Var(&settings.Score.QoS, "score-qos-weight", 0,
+		"Weight of the pod's QoS class (BestEffort first, Guaranteed last) in the score ordering collection")
+	cmd.Flags().Float64Var(&settings.Score.Restarts, "score-restarts-weight", 0,
+		"Weight of the pod's containers restarts in the score ordering collection")
+	cmd.Flags().Float64Var(&settings.Score.OwnerSize, "score-owner-size-weight", 0,
+		"Weight of the pod's workload replicas in the score ordering collection")
+	cmd.Flags().BoolVar(&settings.Exclusions.BarePods, "skip-bare-pods", true,
+		"Never collect pods without a controller, as they wouldn't be recreated")
+	cmd.Flags().BoolVar(&settings.Exclusions.DaemonSetPods, "skip-daemonset-pods", true,
+		"Never collect pods owned by a DaemonSet")
+	cmd.Flags().BoolVar(&settings.Exclusions.JobPods, "skip-job-pods", true,
+		"Never collect pods owned by a Job")
+	cmd.Flags().BoolVar(&settings.Exclusions.LocalStorage, "skip-local-storage", true,
+		"Never collect pods with an emptyDir volume, as its data would be lost")
+}
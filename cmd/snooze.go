@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snoozeCmd = &cobra.Command{
+		Use:   "snooze <pod>",
+		Short: "Postpone the collection of a pod",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := cmd.Flags().GetString("namespace")
+			if err != nil {
+				return err
+			}
+			duration, err := cmd.Flags().GetDuration("for")
+			if err != nil {
+				return err
+			}
+			if duration <= 0 {
+				return fmt.Errorf("snooze duration must be positive, got %v", duration)
+			}
+
+			k8sClient, err := provideKubernetesClient(cmd)
+			if err != nil {
+				return err
+			}
+			until := time.Now().Add(duration)
+			if err := k8sClient.SnoozePod(cmd.Context(), namespace, args[0], until); err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"pod":       args[0],
+				"namespace": namespace,
+				"until":     until.UTC().Format(time.RFC3339),
+			}).Info("pod snoozed")
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(snoozeCmd)
+
+	snoozeCmd.Flags().StringP("namespace", "n", "default", "Namespace of the pod")
+	snoozeCmd.Flags().Duration("for", 4*time.Hour, "Duration during which the pod won't be collected")
+	addKubeFlags(snoozeCmd)
+}
@@ -2,9 +2,12 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/backmarket-oss/raccoon/internal/k8s"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type Strategy interface {
@@ -12,11 +15,203 @@ type Strategy interface {
 	Run(ctx context.Context) error
 }
 
+const (
+	// ActionEvict evicts marked pods one by one.
+	ActionEvict = "evict"
+	// ActionRolloutRestart restarts the workload owning the marked pod.
+	ActionRolloutRestart = "rollout-restart"
+)
+
 type DefaultSettings struct {
-	Namespace string
-	Selector  string
-	TTL       time.Duration
-	DryRun    bool
+	Namespace       string
+	Selector        string
+	TTL             time.Duration
+	DryRun          bool
+	Action          string
+	RestartCooldown time.Duration
+	AgeSource       string
+	// FieldSelector selects the pods along with Selector, among which the pods matching both
+	// ExcludeSelector and ExcludeFieldSelector are left out.
+	FieldSelector        string
+	ExcludeSelector      string
+	ExcludeFieldSelector string
+	// MinReadyReplicas is the minimum number of ready replicas a workload must keep after an eviction.
+	MinReadyReplicas int
+	// MaxUnhealthyRatio is the ratio of unhealthy pods above which collection is suspended, 0 disables the gate.
+	MaxUnhealthyRatio float64
+	// BreakerFailures is the number of replacement failures opening a circuit, 0 disables the breaker.
+	BreakerFailures int
+	BreakerDeadline time.Duration
+	BreakerCooldown time.Duration
+	// EvictionBackoff is the initial delay before retrying an eviction refused by a disruption budget.
+	EvictionBackoff time.Duration
+	// MaxLateness is how long a pod can stay past its ttl before its blocked eviction is escalated, 0 disables it.
+	MaxLateness time.Duration
+	// EscalationDelete deletes the escalated pods, bypassing their disruption budget.
+	EscalationDelete      bool
+	EscalationGracePeriod time.Duration
+	// TopologyWindow is the minimum duration between two collections on the same node or in the same zone.
+	TopologyWindow time.Duration
+	// MaxZoneDisruptions is the maximum number of disrupted pods per zone, 0 disables the limit.
+	MaxZoneDisruptions int
+	Score              ScoreWeights
+	// SurgeMaxReplicas is the number of replicas up to which a Deployment is scaled up by one replica
+	// before evicting one of its pods, 0 disables surging.
+	SurgeMaxReplicas int
+	SurgeTimeout     time.Duration
+	// SkipUnstableWorkloads defers collection in workloads being rolled out or whose autoscaler is at its max.
+	SkipUnstableWorkloads bool
+	// DrainPeriod is waited for between draining a pod declaring the drain gate and evicting it.
+	DrainPeriod time.Duration
+	// PreEvictHookTimeout is the maximum duration waited for a pod's pre-evict hook to respond.
+	PreEvictHookTimeout time.Duration
+	// EvictionNotice is how long before their expiry pods are annotated with the date they are collected at,
+	// 0 disables the notice.
+	EvictionNotice time.Duration
+	// CollectStaleTemplates collects the pods which differ from their owner's current template, whatever their age.
+	CollectStaleTemplates bool
+	// CollectConfigChanges collects the pods whose ConfigMaps or Secrets changed after they started.
+	CollectConfigChanges bool
+	// MaxRestarts is the number of containers restarts from which a pod is collected, 0 disables the criterion.
+	MaxRestarts int
+	// OOMKillWindow collects the pods with a container OOM killed during this window, 0 disables the criterion.
+	OOMKillWindow time.Duration
+	// MemoryLimitRatio collects the pods with a container using more than this ratio of its memory limit
+	// for MemorySustainPeriod, 0 disables the criterion.
+	MemoryLimitRatio    float64
+	MemorySustainPeriod time.Duration
+	Exclusions          Exclusions
+}
+
+// Exclusions are the kinds of pods never collected, whatever their age.
+type Exclusions struct {
+	// BarePods excludes the pods without a controller, they wouldn't be recreated.
+	BarePods bool
+	// DaemonSetPods excludes the pods of DaemonSets, they are recreated on the same node.
+	DaemonSetPods bool
+	// JobPods excludes the pods of Jobs, evicting them interrupts the job.
+	JobPods bool
+	// LocalStorage excludes the pods with an emptyDir volume, its data would be lost.
+	LocalStorage bool
+}
+
+// ScoreWeights weights the criteria of the score ordering the collection of pods, highest scores first.
+// Pods are ordered by age when all weights are 0.
+type ScoreWeights struct {
+	// Age weights the pod's age over its ttl.
+	Age float64
+	// Priority favours pods with a low priority.
+	Priority float64
+	// QoS favours BestEffort pods, then Burstable ones.
+	QoS float64
+	// Restarts favours pods whose containers restarted.
+	Restarts float64
+	// OwnerSize favours pods of workloads with many replicas.
+	OwnerSize float64
+}
+
+// Enabled reports whether at least one criterion is weighted.
+func (w ScoreWeights) Enabled() bool {
+	return w.Age > 0 || w.Priority > 0 || w.QoS > 0 || w.Restarts > 0 || w.OwnerSize > 0
+}
+
+// Validate checks the settings which can't be checked by flags parsing.
+func (s DefaultSettings) Validate() error {
+	for _, validate := range []func() error{s.validateSelectors, s.validateModes, s.validateCounts, s.validateRatios} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateModes checks the settings choosing between several behaviours.
+func (s DefaultSettings) validateModes() error {
+	if s.Action != ActionEvict && s.Action != ActionRolloutRestart {
+		return fmt.Errorf("unknown action %v, please use either '%s' or '%s'",
+			s.Action, ActionEvict, ActionRolloutRestart)
+	}
+	if !k8s.ValidAgeSource(s.AgeSource) {
+		return fmt.Errorf("unknown age source %v, please use either '%s', '%s' or '%s'", s.AgeSource,
+			k8s.AgeSourceCreation, k8s.AgeSourceStartTime, k8s.AgeSourceOldestContainerStart)
+	}
+	return nil
+}
+
+// validateCounts checks the settings counting pods, replicas or failures, 0 disabling most of them.
+func (s DefaultSettings) validateCounts() error {
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"min ready replicas", s.MinReadyReplicas},
+		{"breaker failures", s.BreakerFailures},
+		{"max zone disruptions", s.MaxZoneDisruptions},
+		{"surge max replicas", s.SurgeMaxReplicas},
+		{"max restarts", s.MaxRestarts},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			return fmt.Errorf("%s must be positive, got %d", count.name, count.value)
+		}
+	}
+	return nil
+}
+
+// validateRatios checks the ratios and the score weights.
+func (s DefaultSettings) validateRatios() error {
+	if s.MaxUnhealthyRatio < 0 || s.MaxUnhealthyRatio > 1 {
+		return fmt.Errorf("max unhealthy ratio must be between 0 and 1, got %v", s.MaxUnhealthyRatio)
+	}
+	if s.MemoryLimitRatio < 0 || s.MemoryLimitRatio > 1 {
+		return fmt.Errorf("memory limit ratio must be between 0 and 1, got %v", s.MemoryLimitRatio)
+	}
+	if s.Score.Age < 0 || s.Score.Priority < 0 || s.Score.QoS < 0 || s.Score.Restarts < 0 || s.Score.OwnerSize < 0 {
+		return fmt.Errorf("score weights must be positive, got %+v", s.Score)
+	}
+	return nil
+}
+
+// validateSelectors parses the selectors up front, so a typo fails at startup instead of at every check.
+func (s DefaultSettings) validateSelectors() error {
+	for _, selector := range []string{s.Selector, s.ExcludeSelector} {
+		if _, err := labels.Parse(selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %v", selector, err)
+		}
+	}
+	for _, selector := range []string{s.FieldSelector, s.ExcludeFieldSelector} {
+		if _, err := k8s.ParseFieldSelector(selector); err != nil {
+			return fmt.Errorf("invalid field selector %q: %v", selector, err)
+		}
+	}
+	return nil
+}
+
+// NodeSettings configures the recycling of nodes.
+type NodeSettings struct {
+	Selector string
+	TTL      time.Duration
+	DryRun   bool
+	// DeleteNodes deletes the drained nodes, so the cluster autoscaler replaces them.
+	DeleteNodes bool
+	// MaxConcurrentNodes is the maximum number of nodes being recycled at the same time.
+	MaxConcurrentNodes int
+	// Exclusions are the kinds of pods never evicted, they keep their node from being drained.
+	Exclusions Exclusions
+}
+
+// Validate checks the settings which can't be checked by flags parsing.
+func (s NodeSettings) Validate() error {
+	if _, err := labels.Parse(s.Selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %v", s.Selector, err)
+	}
+	if s.TTL <= 0 {
+		return fmt.Errorf("node ttl must be positive, got %v", s.TTL)
+	}
+	if s.MaxConcurrentNodes < 1 {
+		return fmt.Errorf("max concurrent nodes must be at least 1, got %d", s.MaxConcurrentNodes)
+	}
+	return nil
 }
 
 // RunDaemon is the main loop driven by a check interval.
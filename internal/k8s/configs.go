@@ -0,0 +1,151 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/metadata"
+)
+
+const (
+	KindConfigMap = "ConfigMap"
+	KindSecret    = "Secret"
+)
+
+// ErrNoMetadataClient is returned when reading ConfigMaps or Secrets from a client without metadata client.
+var ErrNoMetadataClient = errors.New("k8s: no metadata client")
+
+// UseMetadata sets the client reading the metadata of ConfigMaps and Secrets, so their data is never read.
+func (k *KubernetesClient) UseMetadata(metadata metadata.Interface) {
+	k.metadata = metadata
+}
+
+// configMeta reads the metadata of a ConfigMap or a Secret, resource being either configmaps or secrets.
+func (k KubernetesClient) configMeta(ctx context.Context, owner Owner, resource string) (metav1.Object, error) {
+	if k.metadata == nil {
+		return nil, ErrNoMetadataClient
+	}
+	return k.metadata.Resource(v1.SchemeGroupVersion.WithResource(resource)).Namespace(owner.Namespace).
+		Get(ctx, owner.Name, metav1.GetOptions{})
+}
+
+// ChangedConfig returns the first ConfigMap or Secret referenced by the pod, through its volumes, env or envFrom,
+// whose data changed after the pod started, nil when none did. Objects which don't exist are ignored.
+// Only the metadata of the objects is read, from the cache, so a change may take a few minutes to be noticed.
+func (k KubernetesClient) ChangedConfig(ctx context.Context, pod v1.Pod) (*Owner, error) {
+	if pod.Status.StartTime == nil {
+		return nil, nil
+	}
+	for _, config := range referencedConfigs(pod) {
+		meta, err := k.objectMeta(ctx, config)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %v", config)
+		}
+		if lastDataUpdate(meta).After(pod.Status.StartTime.Time) {
+			config := config
+			return &config, nil
+		}
+	}
+	return nil, nil
+}
+
+// referencedConfigs returns the ConfigMaps and Secrets referenced by the pod's volumes and containers, once each.
+func referencedConfigs(pod v1.Pod) []Owner {
+	refs := &configRefs{namespace: pod.Namespace, seen: make(map[Owner]bool)}
+	for _, volume := range pod.Spec.Volumes {
+		refs.addVolume(volume)
+	}
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			refs.addContainer(container)
+		}
+	}
+	return refs.configs
+}
+
+// configRefs collects the ConfigMaps and Secrets referenced in a namespace, once each.
+type configRefs struct {
+	namespace string
+	configs   []Owner
+	seen      map[Owner]bool
+}
+
+func (r *configRefs) add(kind, name string) {
+	config := Owner{Kind: kind, Namespace: r.namespace, Name: name}
+	if name != "" && !r.seen[config] {
+		r.seen[config] = true
+		r.configs = append(r.configs, config)
+	}
+}
+
+func (r *configRefs) addVolume(volume v1.Volume) {
+	if volume.ConfigMap != nil {
+		r.add(KindConfigMap, volume.ConfigMap.Name)
+	}
+	if volume.Secret != nil {
+		r.add(KindSecret, volume.Secret.SecretName)
+	}
+	if volume.Projected == nil {
+		return
+	}
+	for _, source := range volume.Projected.Sources {
+		if source.ConfigMap != nil {
+			r.add(KindConfigMap, source.ConfigMap.Name)
+		}
+		if source.Secret != nil {
+			r.add(KindSecret, source.Secret.Name)
+		}
+	}
+}
+
+func (r *configRefs) addContainer(container v1.Container) {
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.ConfigMapRef != nil {
+			r.add(KindConfigMap, envFrom.ConfigMapRef.Name)
+		}
+		if envFrom.SecretRef != nil {
+			r.add(KindSecret, envFrom.SecretRef.Name)
+		}
+	}
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if env.ValueFrom.ConfigMapKeyRef != nil {
+			r.add(KindConfigMap, env.ValueFrom.ConfigMapKeyRef.Name)
+		}
+		if env.ValueFrom.SecretKeyRef != nil {
+			r.add(KindSecret, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+}
+
+// lastDataUpdate returns when the data of a ConfigMap or a Secret was last updated, as far as its managed fields
+// tell: the latest operation of a manager owning some of its data, or its creation. A manager's entry only tells
+// when it last applied or updated the object, so changing the labels or annotations with the manager of the data,
+// e.g. the same `kubectl apply`, counts as a change of the data too.
+func lastDataUpdate(meta metav1.Object) time.Time {
+	last := meta.GetCreationTimestamp().Time
+	for _, entry := range meta.GetManagedFields() {
+		if entry.Time == nil || entry.FieldsV1 == nil || !ownsData(entry.FieldsV1.Raw) {
+			continue
+		}
+		if entry.Time.Time.After(last) {
+			last = entry.Time.Time
+		}
+	}
+	return last
+}
+
+// ownsData reports whether a managed fields set holds some data of a ConfigMap or a Secret.
+func ownsData(fields []byte) bool {
+	return bytes.Contains(fields, []byte(`"f:data"`)) || bytes.Contains(fields, []byte(`"f:binaryData"`))
+}
@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func TestChangedConfig(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod      v1.Pod
+		expected *Owner
+	}
+
+	started := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC)
+	managedFields := func(updated time.Time, fields string) []metav1.ManagedFieldsEntry {
+		return []metav1.ManagedFieldsEntry{{
+			Manager:  "kubectl",
+			Time:     &metav1.Time{Time: updated},
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)},
+		}}
+	}
+	configMeta := func(kind, name string, created time.Time, managedFields []metav1.ManagedFieldsEntry) runtime.Object {
+		return &metav1.PartialObjectMetadata{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: kind},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "ns1",
+				CreationTimestamp: metav1.NewTime(created),
+				ManagedFields:     managedFields,
+			},
+		}
+	}
+	// the data and the labels of relabelled-by-owner have the same manager, a change of its labels only can't be
+	// told apart from a change of its data
+	scheme := metadatafake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme,
+		configMeta(KindConfigMap, "unchanged", started.Add(-time.Hour),
+			managedFields(started.Add(-time.Minute), `{"f:data":{"f:key":{}}}`)),
+		configMeta(KindConfigMap, "relabelled", started.Add(-time.Hour),
+			managedFields(started.Add(time.Hour), `{"f:metadata":{"f:labels":{"f:app":{}}}}`)),
+		configMeta(KindConfigMap, "relabelled-by-owner", started.Add(-time.Hour),
+			managedFields(started.Add(time.Hour), `{"f:data":{"f:key":{}},"f:metadata":{"f:labels":{"f:app":{}}}}`)),
+		configMeta(KindSecret, "rotated", started.Add(-time.Hour),
+			managedFields(started.Add(time.Hour), `{"f:data":{"f:password":{}}}`)),
+		configMeta(KindSecret, "recreated", started.Add(time.Hour), nil),
+	)
+	k8sClient := InitKubernetesClient(testclient.NewSimpleClientset())
+	k8sClient.UseMetadata(metadataClient)
+	podWith := func(spec v1.PodSpec) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1"},
+			Spec:       spec,
+			Status:     v1.PodStatus{StartTime: &metav1.Time{Time: started}},
+		}
+	}
+
+	data := map[string]unitData{
+		"unchanged volume": {
+			pod: podWith(v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "unchanged"}},
+			}}}}),
+		},
+		"metadata changed": {
+			pod: podWith(v1.PodSpec{Containers: []v1.Container{{EnvFrom: []v1.EnvFromSource{{
+				ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "relabelled"}},
+			}}}}}),
+		},
+		"metadata changed by data's manager": {
+			pod: podWith(v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: "relabelled-by-owner"},
+				},
+			}}}}),
+			expected: &Owner{Kind: KindConfigMap, Namespace: "ns1", Name: "relabelled-by-owner"},
+		},
+		"rotated secret in env": {
+			pod: podWith(v1.PodSpec{Containers: []v1.Container{{Env: []v1.EnvVar{{ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "rotated"}},
+			}}}}}}),
+			expected: &Owner{Kind: KindSecret, Namespace: "ns1", Name: "rotated"},
+		},
+		"recreated secret in projected volume": {
+			pod: podWith(v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{{
+					Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "recreated"}},
+				}}},
+			}}}}),
+			expected: &Owner{Kind: KindSecret, Namespace: "ns1", Name: "recreated"},
+		},
+		"missing optional secret": {
+			pod: podWith(v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: "missing"},
+			}}}}),
+		},
+		"pod not started": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1"}, Spec: v1.PodSpec{Volumes: []v1.Volume{{
+				VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "rotated"}},
+			}}}},
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				changed, err := k8sClient.ChangedConfig(context.Background(), unit.pod)
+				assert.NoError(t, err)
+				assert.Equal(t, unit.expected, changed)
+			}
+		}(unit))
+	}
+}
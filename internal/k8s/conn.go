@@ -5,8 +5,10 @@ import (
 
 	"github.com/pkg/errors"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 // AuthenticateToCluster returns a Clientset depending if you are in cluster or out cluster.
@@ -21,6 +23,52 @@ func AuthenticateToCluster(location, kubeConfig string) (*kubernetes.Clientset,
 	}
 }
 
+// MetricsClientForCluster returns a client of the metrics.k8s.io api, the same way AuthenticateToCluster does.
+func MetricsClientForCluster(location, kubeConfig string) (*metricsclient.Clientset, error) {
+	config, err := clientConfig(location, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	metricsClient, err := metricsclient.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate metrics client")
+	}
+	return metricsClient, nil
+}
+
+// MetadataClientForCluster returns a client reading the metadata of objects only, the same way AuthenticateToCluster
+// does.
+func MetadataClientForCluster(location, kubeConfig string) (metadata.Interface, error) {
+	config, err := clientConfig(location, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate metadata client")
+	}
+	return metadataClient, nil
+}
+
+func clientConfig(location, kubeConfig string) (*rest.Config, error) {
+	var (
+		config *rest.Config
+		err    error
+	)
+	switch location {
+	case "in":
+		config, err = rest.InClusterConfig()
+	case "out":
+		config, err = clientcmd.BuildConfigFromFlags("", kubeConfig)
+	default:
+		return nil, fmt.Errorf("k8s: unknown cluster location, please use either 'in' our 'out', %v", location)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get client config")
+	}
+	return config, nil
+}
+
 func authenticateInCluster() (*kubernetes.Clientset, error) {
 	// creates the in-cluster config
 	config, err := rest.InClusterConfig()
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DrainConditionType is the readiness gate raccoon manages on pods declaring it:
+	// it is True while the pod serves traffic, and set to False to drain the pod before evicting it.
+	DrainConditionType v1.PodConditionType = "raccoon.backmarket.com/drain"
+
+	drainReasonServing  = "Serving"
+	drainReasonDraining = "Draining"
+)
+
+// HasDrainGate reports whether the pod declares the drain readiness gate.
+func HasDrainGate(pod v1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == DrainConditionType {
+			return true
+		}
+	}
+	return false
+}
+
+// DrainGateOpen reports whether the pod's drain condition is True, the pod isn't ready until it is.
+func DrainGateOpen(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == DrainConditionType {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// SetDrainGate sets the pod's drain condition, open (True) to serve traffic or closed (False) to drain it.
+func (k KubernetesClient) SetDrainGate(ctx context.Context, namespace, name string, open bool) error {
+	status, reason := v1.ConditionFalse, drainReasonDraining
+	if open {
+		status, reason = v1.ConditionTrue, drainReasonServing
+	}
+	patch := []byte(fmt.Sprintf(`{"status":{"conditions":[{"type":%q,"status":%q,"reason":%q,"lastTransitionTime":%q}]}}`,
+		DrainConditionType, status, reason, time.Now().UTC().Format(time.RFC3339)))
+
+	_, err := k.clientSet.CoreV1().Pods(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch,
+		metav1.PatchOptions{}, "status")
+	if err != nil {
+		return errors.Wrap(err, "failed to set pod's drain gate")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDrainGate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	gated := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1"},
+		Spec:       v1.PodSpec{ReadinessGates: []v1.PodReadinessGate{{ConditionType: DrainConditionType}}},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{
+			{Type: v1.PodReady, Status: v1.ConditionFalse},
+		}},
+	}
+	clientSet := testclient.NewSimpleClientset(gated)
+	k8sClient := InitKubernetesClient(clientSet)
+
+	assert.True(t, HasDrainGate(*gated))
+	assert.False(t, HasDrainGate(v1.Pod{}))
+	assert.False(t, DrainGateOpen(*gated))
+
+	assert.Nil(t, k8sClient.SetDrainGate(ctx, "ns1", "pod-1", true))
+	pod, err := k8sClient.GetPod(ctx, "ns1", "pod-1")
+	assert.Nil(t, err)
+	assert.True(t, DrainGateOpen(*pod))
+	// other conditions are kept
+	assert.Len(t, pod.Status.Conditions, 2)
+
+	assert.Nil(t, k8sClient.SetDrainGate(ctx, "ns1", "pod-1", false))
+	pod, err = k8sClient.GetPod(ctx, "ns1", "pod-1")
+	assert.Nil(t, err)
+	assert.False(t, DrainGateOpen(*pod))
+	assert.Len(t, pod.Status.Conditions, 2)
+}
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	eventSource = "raccoon"
+
+	// EventTypeNormal is an event about an expected action.
+	EventTypeNormal = v1.EventTypeNormal
+	// EventTypeWarning is an event about an action which needs attention.
+	EventTypeWarning = v1.EventTypeWarning
+)
+
+// Reference returns the object reference of the owner, to be used as an event's involved object.
+func (o Owner) Reference() v1.ObjectReference {
+	apiVersion := "apps/v1"
+	switch o.Kind {
+	case KindJob, KindCronJob:
+		apiVersion = "batch/v1"
+	case KindNamespace, KindNode:
+		apiVersion = "v1"
+	}
+	return v1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       o.Kind,
+		Namespace:  o.Namespace,
+		Name:       o.Name,
+	}
+}
+
+// PodReference returns the object reference of a pod, to be used as an event's involved object.
+func PodReference(namespace, name string) v1.ObjectReference {
+	return v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  namespace,
+		Name:       name,
+	}
+}
+
+// EmitEvent records a kubernetes event on the involved object, visible with `kubectl describe`.
+func (k KubernetesClient) EmitEvent(ctx context.Context, involved v1.ObjectReference,
+	eventType, reason, message string) error {
+	namespace := involved.Namespace
+	switch involved.Kind {
+	case KindNamespace:
+		namespace = involved.Name
+	case KindNode:
+		// events on cluster scoped objects are recorded in the default namespace, as kubelet does for nodes
+		namespace = metav1.NamespaceDefault
+	}
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", involved.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: involved,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         v1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := k.clientSet.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to create event")
+	}
+	return nil
+}
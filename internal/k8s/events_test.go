@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEmitEvent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset()
+	k8sClient := InitKubernetesClient(clientSet)
+
+	owner := Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"}
+	err := k8sClient.EmitEvent(ctx, owner.Reference(), EventTypeWarning, "CircuitOpen", "too many failures")
+	assert.Nil(t, err)
+	err = k8sClient.EmitEvent(ctx, Owner{Kind: KindNamespace, Name: "ns2"}.Reference(),
+		EventTypeWarning, "CircuitOpen", "too many failures")
+	assert.Nil(t, err)
+
+	events, err := clientSet.CoreV1().Events("ns1").List(ctx, metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, events.Items, 1)
+	assert.Equal(t, "apps/v1", events.Items[0].InvolvedObject.APIVersion)
+	assert.Equal(t, "CircuitOpen", events.Items[0].Reason)
+	assert.Equal(t, eventSource, events.Items[0].Source.Component)
+
+	events, err = clientSet.CoreV1().Events("ns2").List(ctx, metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, events.Items, 1)
+}
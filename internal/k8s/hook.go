@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PreEvictHookAnnotation names the port and path raccoon calls on the pod before evicting it, e.g. "8080/pre-stop".
+	PreEvictHookAnnotation = "backmarket.com/raccoon-pre-evict-hook"
+)
+
+// PreEvictHookURL returns the url of the pod's pre-evict hook, on the pod's IP.
+// It returns an empty url when the pod isn't annotated.
+func PreEvictHookURL(pod v1.Pod) (string, error) {
+	value, ok := pod.ObjectMeta.GetAnnotations()[PreEvictHookAnnotation]
+	if !ok {
+		return "", nil
+	}
+	parts := strings.SplitN(value, "/", 2)
+	port, err := strconv.Atoi(parts[0])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", fmt.Errorf("invalid %s annotation %q, it must be given as port/path", PreEvictHookAnnotation, value)
+	}
+	path := "/"
+	if len(parts) == 2 {
+		path += parts[1]
+	}
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("pod has no IP to call its pre-evict hook")
+	}
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)), path), nil
+}
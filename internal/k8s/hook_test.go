@@ -0,0 +1,64 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPreEvictHookURL(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		annotations map[string]string
+		podIP       string
+		url         string
+		err         bool
+	}
+
+	data := map[string]unitData{
+		"no hook": {
+			podIP: "10.0.0.1",
+		},
+		"port and path": {
+			annotations: map[string]string{PreEvictHookAnnotation: "8080/pre-stop"},
+			podIP:       "10.0.0.1",
+			url:         "http://10.0.0.1:8080/pre-stop",
+		},
+		"port only": {
+			annotations: map[string]string{PreEvictHookAnnotation: "8080"},
+			podIP:       "10.0.0.1",
+			url:         "http://10.0.0.1:8080/",
+		},
+		"ipv6": {
+			annotations: map[string]string{PreEvictHookAnnotation: "8080/pre-stop"},
+			podIP:       "fd00::1",
+			url:         "http://[fd00::1]:8080/pre-stop",
+		},
+		"invalid port": {
+			annotations: map[string]string{PreEvictHookAnnotation: "http/pre-stop"},
+			podIP:       "10.0.0.1",
+			err:         true,
+		},
+		"no pod ip": {
+			annotations: map[string]string{PreEvictHookAnnotation: "8080/pre-stop"},
+			err:         true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+				url, err := PreEvictHookURL(v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Annotations: unit.annotations},
+					Status:     v1.PodStatus{PodIP: unit.podIP},
+				})
+				assert.Equal(t, unit.err, err != nil)
+				assert.Equal(t, unit.url, url)
+			}
+		}(unit))
+	}
+}
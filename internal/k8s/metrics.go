@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// ErrNoMetricsClient is returned when reading metrics from a client without metrics.k8s.io api.
+var ErrNoMetricsClient = errors.New("k8s: no metrics client")
+
+// UseMetrics sets the client reading the metrics.k8s.io api.
+func (k *KubernetesClient) UseMetrics(metrics metricsclient.Interface) {
+	k.metrics = metrics
+}
+
+// PodsWorkingSet returns the memory working set in bytes of the pods' containers, by pod and container name.
+// Pods without metrics yet are missing.
+func (k KubernetesClient) PodsWorkingSet(ctx context.Context, namespace,
+	labelSelector string) (map[types.NamespacedName]map[string]int64, error) {
+	if k.metrics == nil {
+		return nil, ErrNoMetricsClient
+	}
+	podsMetrics, err := k.metrics.MetricsV1beta1().PodMetricses(namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pods metrics")
+	}
+
+	workingSets := make(map[types.NamespacedName]map[string]int64, len(podsMetrics.Items))
+	for _, podMetrics := range podsMetrics.Items {
+		containers := make(map[string]int64, len(podMetrics.Containers))
+		for _, container := range podMetrics.Containers {
+			containers[container.Name] = container.Usage.Memory().Value()
+		}
+		workingSets[types.NamespacedName{Namespace: podMetrics.Namespace, Name: podMetrics.Name}] = containers
+	}
+	return workingSets, nil
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// fakeMetrics serves the given pods metrics as the metrics.k8s.io api, filtered by namespace.
+func fakeMetrics(podsMetrics ...metricsv1beta1.PodMetrics) *metricsfake.Clientset {
+	metrics := &metricsfake.Clientset{}
+	metrics.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := &metricsv1beta1.PodMetricsList{}
+		for _, podMetrics := range podsMetrics {
+			if ns := action.GetNamespace(); ns == "" || ns == podMetrics.Namespace {
+				list.Items = append(list.Items, podMetrics)
+			}
+		}
+		return true, list, nil
+	})
+	return metrics
+}
+
+func memoryUsage(quantity string) v1.ResourceList {
+	return v1.ResourceList{v1.ResourceMemory: resource.MustParse(quantity)}
+}
+
+func TestPodsWorkingSet(t *testing.T) {
+	t.Parallel()
+
+	k8sClient := InitKubernetesClient(testclient.NewSimpleClientset())
+	_, err := k8sClient.PodsWorkingSet(context.Background(), "ns1", "")
+	assert.Equal(t, ErrNoMetricsClient, err)
+
+	k8sClient.UseMetrics(fakeMetrics(
+		metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Name: "app", Usage: memoryUsage("512Mi")},
+				{Name: "proxy", Usage: memoryUsage("64Mi")},
+			},
+		},
+		metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "ns2"},
+			Containers: []metricsv1beta1.ContainerMetrics{{Name: "app", Usage: memoryUsage("1Gi")}},
+		},
+	))
+	workingSets, err := k8sClient.PodsWorkingSet(context.Background(), "ns1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[types.NamespacedName]map[string]int64{
+		{Namespace: "ns1", Name: "pod-1"}: {"app": 512 << 20, "proxy": 64 << 20},
+	}, workingSets)
+}
@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	KindNode = "Node"
+
+	// RecyclingAnnotation is set on the nodes cordoned by raccoon, with the date they have been cordoned at (RFC3339).
+	RecyclingAnnotation = "backmarket.com/raccoon-recycling"
+	// mirrorPodAnnotation is set by the kubelet on the mirror pods of static pods, which can't be evicted.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// NodeZone returns the zone of a node, from its topology.kubernetes.io/zone label.
+// It is empty when the node isn't labelled.
+func (k KubernetesClient) NodeZone(ctx context.Context, nodeName string) (string, error) {
+	meta, err := k.objectMeta(ctx, Owner{Kind: KindNode, Name: nodeName})
+	if err != nil {
+		return "", err
+	}
+	return meta.GetLabels()[v1.LabelTopologyZone], nil
+}
+
+// ListNodes returns the nodes matching the label selector, the oldest first.
+func (k KubernetesClient) ListNodes(ctx context.Context, labelSelector string) ([]v1.Node, error) {
+	nodes, err := k.clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	sort.SliceStable(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].CreationTimestamp.Before(&nodes.Items[j].CreationTimestamp)
+	})
+	return nodes.Items, nil
+}
+
+// CordonNode marks the node unschedulable, and annotates it as being recycled by raccoon.
+func (k KubernetesClient) CordonNode(ctx context.Context, name string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},"spec":{"unschedulable":true}}`,
+		RecyclingAnnotation, time.Now().UTC().Format(time.RFC3339)))
+	_, err := k.clientSet.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to cordon node")
+	}
+	return nil
+}
+
+// DeleteNode deletes the node object, so the cluster autoscaler replaces it.
+func (k KubernetesClient) DeleteNode(ctx context.Context, name string) error {
+	if err := k.clientSet.CoreV1().Nodes().Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return errors.Wrap(err, "failed to delete node")
+	}
+	return nil
+}
+
+// NodePods returns the pods running on the node which must be gone for it to be drained, including the pods being
+// deleted: DaemonSet pods, mirror pods and terminated pods are left out, as `kubectl drain` leaves them out.
+func (k KubernetesClient) NodePods(ctx context.Context, name string) ([]v1.Pod, error) {
+	pods, err := k.clientSet.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list node's pods")
+	}
+
+	var drained []v1.Pod
+	for _, pod := range pods.Items {
+		controller := metav1.GetControllerOf(&pod)
+		switch {
+		case pod.Spec.NodeName != name:
+		case pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed:
+		case pod.Annotations[mirrorPodAnnotation] != "":
+		case controller != nil && controller.Kind == KindDaemonSet:
+		default:
+			drained = append(drained, pod)
+		}
+	}
+	return drained, nil
+}
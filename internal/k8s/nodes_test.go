@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeZone(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{v1.LabelTopologyZone: "eu-west-3a"},
+		}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	)
+	k8sClient := InitKubernetesClient(clientSet)
+
+	zone, err := k8sClient.NodeZone(ctx, "node-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-3a", zone)
+	zone, err = k8sClient.NodeZone(ctx, "node-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "", zone)
+	_, err = k8sClient.NodeZone(ctx, "node-3")
+	assert.NotNil(t, err)
+
+	// nodes are cached
+	_, _ = k8sClient.NodeZone(ctx, "node-1")
+	assert.Len(t, clientSet.Actions(), 3)
+}
+
+func TestRecycleNode(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	isController := true
+	clientSet := testclient.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-new", CreationTimestamp: metav1.NewTime(time.Now())}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-old",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns1"}, Spec: v1.PodSpec{NodeName: "node-old"}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "ns1", OwnerReferences: []metav1.OwnerReference{
+			{Kind: KindDaemonSet, Name: "agent", Controller: &isController}}}, Spec: v1.PodSpec{NodeName: "node-old"}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "static", Namespace: "kube-system",
+			Annotations: map[string]string{mirrorPodAnnotation: "hash"}}, Spec: v1.PodSpec{NodeName: "node-old"}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "ns1"}, Spec: v1.PodSpec{NodeName: "node-old"},
+			Status: v1.PodStatus{Phase: v1.PodSucceeded}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns1"}, Spec: v1.PodSpec{NodeName: "node-new"}},
+	)
+	k8sClient := InitKubernetesClient(clientSet)
+
+	nodes, err := k8sClient.ListNodes(ctx, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node-old", "node-new"}, []string{nodes[0].Name, nodes[1].Name})
+
+	assert.Nil(t, k8sClient.CordonNode(ctx, "node-old"))
+	node, err := clientSet.CoreV1().Nodes().Get(ctx, "node-old", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+	assert.NotEmpty(t, node.Annotations[RecyclingAnnotation])
+
+	pods, err := k8sClient.NodePods(ctx, "node-old")
+	assert.Nil(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, "app", pods[0].Name)
+
+	assert.Nil(t, k8sClient.DeleteNode(ctx, "node-old"))
+	_, err = clientSet.CoreV1().Nodes().Get(ctx, "node-old", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,323 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	KindJob       = "Job"
+	KindCronJob   = "CronJob"
+	KindNamespace = "Namespace"
+
+	// TTLAnnotation overrides the default ttl, on a pod, its owners or its namespace.
+	TTLAnnotation = "backmarket.com/raccoon-ttl"
+
+	ownerCacheTTL = 5 * time.Minute
+	// maxOwnerDepth protects against ownership cycles, CronJob -> Job -> Pod is the longest chain we know.
+	maxOwnerDepth = 5
+)
+
+// ownerObject is an owner of a pod along with its metadata.
+type ownerObject struct {
+	Owner
+	meta metav1.Object
+}
+
+type cacheEntry struct {
+	meta    metav1.Object
+	expires time.Time
+}
+
+// metaCache keeps objects' metadata for a limited time,
+// pods of the same workload share the same owners so they are looked up once per check.
+// Expired entries are deleted when got, and swept at most once per ttl when setting another one,
+// so the entries of deleted objects don't pile up.
+type metaCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	swept   time.Time
+}
+
+func newMetaCache(ttl time.Duration) *metaCache {
+	return &metaCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *metaCache) get(key string, now time.Time) (metav1.Object, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.meta, true
+}
+
+func (c *metaCache) set(key string, meta metav1.Object, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.swept) >= c.ttl {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[key] = cacheEntry{meta: meta, expires: now.Add(c.ttl)}
+}
+
+// objectMeta returns the metadata of a supported owner kind, of a namespace, of a node, or of a ConfigMap or a Secret,
+// using the cache when possible.
+func (k KubernetesClient) objectMeta(ctx context.Context, owner Owner) (metav1.Object, error) {
+	key := owner.String()
+	if meta, ok := k.cache.get(key, time.Now()); ok {
+		return meta, nil
+	}
+	meta, err := k.getObject(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	k.cache.set(key, meta, time.Now())
+	return meta, nil
+}
+
+// objectGetter gets an object of a kind from the api.
+type objectGetter func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error)
+
+// objectGetters are the getters of the kinds supported by objectMeta.
+var objectGetters = map[string]objectGetter{
+	KindReplicaSet: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.AppsV1().ReplicaSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindDeployment: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.AppsV1().Deployments(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindStatefulSet: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.AppsV1().StatefulSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindDaemonSet: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.AppsV1().DaemonSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindJob: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.BatchV1().Jobs(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindCronJob: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.BatchV1().CronJobs(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindNamespace: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.CoreV1().Namespaces().Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindNode: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.clientSet.CoreV1().Nodes().Get(ctx, owner.Name, metav1.GetOptions{})
+	},
+	KindConfigMap: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.configMeta(ctx, owner, "configmaps")
+	},
+	KindSecret: func(ctx context.Context, k KubernetesClient, owner Owner) (metav1.Object, error) {
+		return k.configMeta(ctx, owner, "secrets")
+	},
+}
+
+// getObject gets an object of a kind supported by objectMeta from the api, bypassing the cache.
+func (k KubernetesClient) getObject(ctx context.Context, owner Owner) (metav1.Object, error) {
+	get, ok := objectGetters[owner.Kind]
+	if !ok {
+		return nil, fmt.Errorf("k8s: unsupported owner kind, %v", owner.Kind)
+	}
+	meta, err := get(ctx, k, owner)
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// ownerChain walks the pod's controller references, from the nearest owner to the farthest one
+// (e.g. Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob).
+// The walk stops on an unsupported kind or on an owner which doesn't exist anymore.
+func (k KubernetesClient) ownerChain(ctx context.Context, pod v1.Pod) ([]ownerObject, error) {
+	var chain []ownerObject
+	ref := metav1.GetControllerOf(&pod)
+	for depth := 0; ref != nil && depth < maxOwnerDepth; depth++ {
+		owner := Owner{Kind: ref.Kind, Namespace: pod.Namespace, Name: ref.Name}
+		if !isSupportedOwnerKind(owner.Kind) {
+			break
+		}
+		meta, err := k.objectMeta(ctx, owner)
+		if apierrors.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get owner %v", owner)
+		}
+		chain = append(chain, ownerObject{Owner: owner, meta: meta})
+		ref = metav1.GetControllerOf(meta)
+	}
+	return chain, nil
+}
+
+func isSupportedOwnerKind(kind string) bool {
+	switch kind {
+	case KindReplicaSet, KindDeployment, KindStatefulSet, KindDaemonSet, KindJob, KindCronJob:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResolveExpiration returns the expiration of a pod. The ttl, the expiry date, the age source, the max restarts
+// and the OOM kill window are each read
+// from the first annotation found on the pod, then on its owners from the nearest to the farthest,
+// then on its namespace. The default ttl is used when none of them has a ttl annotation.
+func (k KubernetesClient) ResolveExpiration(ctx context.Context, pod v1.Pod,
+	defaultTTL time.Duration) (Expiration, error) {
+	r := expirationResolver{uid: pod.UID}
+	if err := r.read(pod.GetAnnotations(), "pod"); err != nil || r.done() {
+		return r.expiration(defaultTTL), err
+	}
+
+	chain, err := k.ownerChain(ctx, pod)
+	if err != nil {
+		return Expiration{}, err
+	}
+	for _, owner := range chain {
+		if err := r.read(owner.meta.GetAnnotations(), owner.Owner.String()); err != nil || r.done() {
+			return r.expiration(defaultTTL), err
+		}
+	}
+
+	nsMeta, err := k.objectMeta(ctx, Owner{Kind: KindNamespace, Name: pod.Namespace})
+	if apierrors.IsNotFound(err) {
+		return r.expiration(defaultTTL), nil
+	}
+	if err != nil {
+		return Expiration{}, errors.Wrap(err, "failed to get namespace")
+	}
+	err = r.read(nsMeta.GetAnnotations(), "namespace "+pod.Namespace)
+	return r.expiration(defaultTTL), err
+}
+
+// expirationResolver keeps the nearest annotations found while walking up the ownership.
+type expirationResolver struct {
+	uid           types.UID
+	ttl           *time.Duration
+	expiresAt     *time.Time
+	ageSource     string
+	maxRestarts   *int
+	oomKillWindow *time.Duration
+}
+
+// read reads the annotations not resolved yet from the annotations of the given source.
+func (r *expirationResolver) read(annotations map[string]string, source string) error {
+	readers := []func(map[string]string, string) error{
+		r.readTTL, r.readExpiresAt, r.readAgeSource, r.readMaxRestarts, r.readOOMKillWindow,
+	}
+	for _, read := range readers {
+		if err := read(annotations, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *expirationResolver) readTTL(annotations map[string]string, source string) error {
+	if r.ttl != nil {
+		return nil
+	}
+	ttl, ok, err := ttlFromAnnotations(annotations, r.uid)
+	if err != nil {
+		return errors.Wrapf(err, "invalid ttl on %v", source)
+	}
+	if ok {
+		r.ttl = &ttl
+	}
+	return nil
+}
+
+func (r *expirationResolver) readExpiresAt(annotations map[string]string, source string) error {
+	if r.expiresAt != nil {
+		return nil
+	}
+	expiresAt, ok, err := expiresAtFromAnnotations(annotations)
+	if err != nil {
+		return errors.Wrapf(err, "invalid expiry date on %v", source)
+	}
+	if ok {
+		r.expiresAt = &expiresAt
+	}
+	return nil
+}
+
+func (r *expirationResolver) readAgeSource(annotations map[string]string, source string) error {
+	if r.ageSource != "" {
+		return nil
+	}
+	ageSource, _, err := ageSourceFromAnnotations(annotations)
+	if err != nil {
+		return errors.Wrapf(err, "invalid age source on %v", source)
+	}
+	r.ageSource = ageSource
+	return nil
+}
+
+func (r *expirationResolver) readMaxRestarts(annotations map[string]string, source string) error {
+	if r.maxRestarts != nil {
+		return nil
+	}
+	maxRestarts, ok, err := maxRestartsFromAnnotations(annotations)
+	if err != nil {
+		return errors.Wrapf(err, "invalid max restarts on %v", source)
+	}
+	if ok {
+		r.maxRestarts = &maxRestarts
+	}
+	return nil
+}
+
+func (r *expirationResolver) readOOMKillWindow(annotations map[string]string, source string) error {
+	if r.oomKillWindow != nil {
+		return nil
+	}
+	window, ok, err := oomKillWindowFromAnnotations(annotations)
+	if err != nil {
+		return errors.Wrapf(err, "invalid OOM kill window on %v", source)
+	}
+	if ok {
+		r.oomKillWindow = &window
+	}
+	return nil
+}
+
+func (r *expirationResolver) done() bool {
+	return r.ttl != nil && r.expiresAt != nil && r.ageSource != "" && r.maxRestarts != nil && r.oomKillWindow != nil
+}
+
+func (r *expirationResolver) expiration(defaultTTL time.Duration) Expiration {
+	e := Expiration{TTL: defaultTTL}
+	if r.ttl != nil {
+		e.TTL = *r.ttl
+	}
+	if r.expiresAt != nil {
+		e.ExpiresAt = *r.expiresAt
+	}
+	e.AgeSource = r.ageSource
+	e.MaxRestarts = r.maxRestarts
+	e.OOMKillWindow = r.oomKillWindow
+	return e
+}
@@ -0,0 +1,210 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveExpiration(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod               v1.Pod
+		expectedTTL       time.Duration
+		expectedExpiresAt time.Time
+		expectedAgeSource string
+		maxRestarts       *int
+		oomKillWindow     *time.Duration
+		expectErr         bool
+	}
+
+	maxRestarts := 10
+	oomKillWindow := 2 * time.Hour
+
+	clientSet := testclient.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "annotated",
+			Annotations: map[string]string{
+				TTLAnnotation:         "12h",
+				ExpiresAtAnnotation:   "2026-11-01T03:00:00Z",
+				MaxRestartsAnnotation: "10",
+			},
+		}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name:            "app-1-5d8f",
+			Namespace:       "plain",
+			OwnerReferences: controllerRef(KindDeployment, "app-1"),
+		}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-1",
+			Namespace: "plain",
+			Annotations: map[string]string{
+				TTLAnnotation:           "2h",
+				AgeSourceAnnotation:     AgeSourceStartTime,
+				OOMKillWindowAnnotation: "2h",
+			},
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:            "job-1",
+			Namespace:       "annotated",
+			OwnerReferences: controllerRef(KindCronJob, "cron-1"),
+		}},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{
+			Name:        "cron-1",
+			Namespace:   "annotated",
+			Annotations: map[string]string{TTLAnnotation: "wrong"},
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      "job-2",
+			Namespace: "annotated",
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:        "job-3",
+			Namespace:   "annotated",
+			Annotations: map[string]string{AgeSourceAnnotation: "scheduling"},
+		}},
+	)
+
+	data := map[string]unitData{
+		"pod annotation first": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "plain",
+				Annotations:     map[string]string{TTLAnnotation: "45s"},
+				OwnerReferences: controllerRef(KindReplicaSet, "app-1-5d8f"),
+			}},
+			expectedTTL:       45 * time.Second,
+			expectedAgeSource: AgeSourceStartTime,
+			oomKillWindow:     &oomKillWindow,
+		},
+		"inherited from deployment": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "plain",
+				OwnerReferences: controllerRef(KindReplicaSet, "app-1-5d8f"),
+			}},
+			expectedTTL:       2 * time.Hour,
+			expectedAgeSource: AgeSourceStartTime,
+			oomKillWindow:     &oomKillWindow,
+		},
+		"age source annotation on pod first": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "plain",
+				Annotations:     map[string]string{AgeSourceAnnotation: AgeSourceOldestContainerStart},
+				OwnerReferences: controllerRef(KindReplicaSet, "app-1-5d8f"),
+			}},
+			expectedTTL:       2 * time.Hour,
+			expectedAgeSource: AgeSourceOldestContainerStart,
+			oomKillWindow:     &oomKillWindow,
+		},
+		"inherited from namespace": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "annotated",
+				OwnerReferences: controllerRef(KindJob, "job-2"),
+			}},
+			expectedTTL:       12 * time.Hour,
+			expectedExpiresAt: time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC),
+			maxRestarts:       &maxRestarts,
+		},
+		"default ttl": {
+			pod:         v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "plain"}},
+			expectedTTL: time.Hour,
+		},
+		"wrong annotation on cronjob": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "annotated",
+				OwnerReferences: controllerRef(KindJob, "job-1"),
+			}},
+			expectErr: true,
+		},
+		"negative max restarts on pod": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "plain",
+				Annotations: map[string]string{MaxRestartsAnnotation: "-1"},
+			}},
+			expectErr: true,
+		},
+		"wrong OOM kill window on pod": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "plain",
+				Annotations: map[string]string{OOMKillWindowAnnotation: "recently"},
+			}},
+			expectErr: true,
+		},
+		"unknown age source on job": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "annotated",
+				OwnerReferences: controllerRef(KindJob, "job-3"),
+			}},
+			expectErr: true,
+		},
+	}
+
+	k8sClient := InitKubernetesClient(clientSet)
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				expiration, err := k8sClient.ResolveExpiration(context.Background(), unit.pod, time.Hour)
+
+				if unit.expectErr {
+					assert.NotNil(t, err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, unit.expectedTTL, expiration.TTL)
+				assert.Equal(t, unit.expectedExpiresAt, expiration.ExpiresAt)
+				assert.Equal(t, unit.expectedAgeSource, expiration.AgeSource)
+				assert.Equal(t, unit.maxRestarts, expiration.MaxRestarts)
+				assert.Equal(t, unit.oomKillWindow, expiration.OOMKillWindow)
+			}
+		}(unit))
+	}
+}
+
+func TestOwnerLookupsAreCached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "ns1",
+		OwnerReferences: controllerRef(KindStatefulSet, "db"),
+	}}
+
+	for i := 0; i < 3; i++ {
+		owner, err := k8sClient.OwnerFromPod(ctx, pod)
+		assert.Nil(t, err)
+		assert.Equal(t, &Owner{Kind: KindStatefulSet, Namespace: "ns1", Name: "db"}, owner)
+	}
+	assert.Len(t, clientSet.Actions(), 1)
+}
+
+func TestMetaCacheDeletesExpiredEntries(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cache := newMetaCache(time.Minute)
+	cache.set("Deployment/ns1/app-1", &metav1.ObjectMeta{Name: "app-1"}, now)
+	cache.set("Deployment/ns1/app-2", &metav1.ObjectMeta{Name: "app-2"}, now)
+
+	_, ok := cache.get("Deployment/ns1/app-1", now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, ok = cache.get("Deployment/ns1/app-1", now.Add(2*time.Minute))
+	assert.False(t, ok)
+	assert.NotContains(t, cache.entries, "Deployment/ns1/app-1", "expired entries are deleted when got")
+
+	// app-2 is never got again, e.g. its deployment has been deleted
+	cache.set("Deployment/ns1/app-3", &metav1.ObjectMeta{Name: "app-3"}, now.Add(2*time.Minute))
+	assert.NotContains(t, cache.entries, "Deployment/ns1/app-2", "expired entries are swept")
+	assert.Contains(t, cache.entries, "Deployment/ns1/app-3")
+}
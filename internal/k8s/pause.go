@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// PausedAnnotation pauses raccoon in a namespace when set to "true".
+	PausedAnnotation = "backmarket.com/raccoon-paused"
+
+	// pause configmap keys
+	pausedKey           = "paused"
+	pausedNamespacesKey = "pausedNamespaces"
+	pauseReasonKey      = "reason"
+)
+
+// pauseWatcher watches the kill switches: a configmap pausing raccoon cluster-wide or in some namespaces,
+// and the paused annotation on namespaces.
+type pauseWatcher struct {
+	configMapNamespace string
+	configMapName      string
+	configMaps         corelisters.ConfigMapLister
+	namespaces         corelisters.NamespaceLister
+}
+
+// WatchPause starts watching the kill switches until the context is done.
+// The configmap is given as namespace/name, no configmap is watched when empty.
+func (k *KubernetesClient) WatchPause(ctx context.Context, configMap string) error {
+	watcher := &pauseWatcher{}
+	var synced []cache.InformerSynced
+
+	if configMap != "" {
+		parts := strings.SplitN(configMap, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("k8s: pause configmap must be given as namespace/name, got %v", configMap)
+		}
+		watcher.configMapNamespace, watcher.configMapName = parts[0], parts[1]
+		cmFactory := informers.NewSharedInformerFactoryWithOptions(k.clientSet, 0,
+			informers.WithNamespace(watcher.configMapNamespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = "metadata.name=" + watcher.configMapName
+			}))
+		cmInformer := cmFactory.Core().V1().ConfigMaps()
+		watcher.configMaps = cmInformer.Lister()
+		synced = append(synced, cmInformer.Informer().HasSynced)
+		cmFactory.Start(ctx.Done())
+	}
+
+	nsFactory := informers.NewSharedInformerFactory(k.clientSet, 0)
+	nsInformer := nsFactory.Core().V1().Namespaces()
+	watcher.namespaces = nsInformer.Lister()
+	synced = append(synced, nsInformer.Informer().HasSynced)
+	nsFactory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return errors.New("k8s: failed to sync pause watchers")
+	}
+	k.pause = watcher
+	return nil
+}
+
+// Paused reports whether raccoon is paused in the namespace, along with the reason.
+// An empty namespace checks the cluster-wide pause only.
+func (k KubernetesClient) Paused(namespace string) (bool, string) {
+	if k.pause == nil {
+		return false, ""
+	}
+	return k.pause.paused(namespace)
+}
+
+// PausedNamespaces returns the namespaces in which raccoon is paused, by the configmap or by their annotation.
+// The cluster-wide pause isn't one of them.
+func (k KubernetesClient) PausedNamespaces() []string {
+	if k.pause == nil {
+		return nil
+	}
+	return k.pause.pausedNamespaces()
+}
+
+func (w *pauseWatcher) pausedNamespaces() []string {
+	var paused []string
+	seen := make(map[string]bool)
+	add := func(namespace string) {
+		if namespace != "" && !seen[namespace] {
+			seen[namespace] = true
+			paused = append(paused, namespace)
+		}
+	}
+	if w.configMaps != nil {
+		if cm, err := w.configMaps.ConfigMaps(w.configMapNamespace).Get(w.configMapName); err == nil {
+			for _, ns := range strings.Split(cm.Data[pausedNamespacesKey], ",") {
+				add(strings.TrimSpace(ns))
+			}
+		}
+	}
+	namespaces, err := w.namespaces.List(labels.Everything())
+	if err != nil {
+		return paused
+	}
+	for _, ns := range namespaces {
+		if ns.GetAnnotations()[PausedAnnotation] == "true" {
+			add(ns.Name)
+		}
+	}
+	return paused
+}
+
+func (w *pauseWatcher) paused(namespace string) (bool, string) {
+	if paused, reason := w.configMapPaused(namespace); paused {
+		return true, reason
+	}
+	if namespace == "" {
+		return false, ""
+	}
+	ns, err := w.namespaces.Get(namespace)
+	if err == nil && ns.GetAnnotations()[PausedAnnotation] == "true" {
+		return true, fmt.Sprintf("namespace %s annotated with %s", namespace, PausedAnnotation)
+	}
+	return false, ""
+}
+
+// configMapPaused reports whether the configmap pauses raccoon cluster-wide, or in the namespace when not empty.
+func (w *pauseWatcher) configMapPaused(namespace string) (bool, string) {
+	if w.configMaps == nil {
+		return false, ""
+	}
+	cm, err := w.configMaps.ConfigMaps(w.configMapNamespace).Get(w.configMapName)
+	if err != nil {
+		return false, ""
+	}
+	reason := fmt.Sprintf("configmap %s/%s", w.configMapNamespace, w.configMapName)
+	if cm.Data[pauseReasonKey] != "" {
+		reason = fmt.Sprintf("%s: %s", reason, cm.Data[pauseReasonKey])
+	}
+	if cm.Data[pausedKey] == "true" {
+		return true, reason
+	}
+	for _, ns := range strings.Split(cm.Data[pausedNamespacesKey], ",") {
+		if namespace != "" && strings.TrimSpace(ns) == namespace {
+			return true, reason
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPaused(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		configMap      *v1.ConfigMap
+		namespace      string
+		expectedPaused bool
+	}
+
+	pauseConfigMap := func(data map[string]string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "raccoon-pause", Namespace: "raccoon"},
+			Data:       data,
+		}
+	}
+
+	data := map[string]unitData{
+		"no configmap": {
+			namespace:      "ns1",
+			expectedPaused: false,
+		},
+		"cluster-wide pause": {
+			configMap:      pauseConfigMap(map[string]string{pausedKey: "true", pauseReasonKey: "incident"}),
+			namespace:      "",
+			expectedPaused: true,
+		},
+		"namespace paused by configmap": {
+			configMap:      pauseConfigMap(map[string]string{pausedNamespacesKey: "ns0, ns1"}),
+			namespace:      "ns1",
+			expectedPaused: true,
+		},
+		"other namespace paused by configmap": {
+			configMap:      pauseConfigMap(map[string]string{pausedNamespacesKey: "ns0"}),
+			namespace:      "ns1",
+			expectedPaused: false,
+		},
+		"namespace paused by annotation": {
+			namespace:      "ns2",
+			expectedPaused: true,
+		},
+		"paused false": {
+			configMap:      pauseConfigMap(map[string]string{pausedKey: "false"}),
+			namespace:      "",
+			expectedPaused: false,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				clientSet := testclient.NewSimpleClientset(
+					&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}},
+					&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+						Name:        "ns2",
+						Annotations: map[string]string{PausedAnnotation: "true"},
+					}},
+				)
+				if unit.configMap != nil {
+					_, err := clientSet.CoreV1().ConfigMaps("raccoon").Create(ctx, unit.configMap, metav1.CreateOptions{})
+					assert.Nil(t, err)
+				}
+				k8sClient := InitKubernetesClient(clientSet)
+				assert.Nil(t, k8sClient.WatchPause(ctx, "raccoon/raccoon-pause"))
+
+				paused, reason := k8sClient.Paused(unit.namespace)
+
+				assert.Equal(t, unit.expectedPaused, paused)
+				if paused {
+					assert.NotEmpty(t, reason)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestPausedNamespaces(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientSet := testclient.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "ns2",
+			Annotations: map[string]string{PausedAnnotation: "true"},
+		}},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "raccoon-pause", Namespace: "raccoon"},
+			Data:       map[string]string{pausedNamespacesKey: "ns0, ns2"},
+		},
+	)
+	k8sClient := InitKubernetesClient(clientSet)
+	assert.Nil(t, k8sClient.WatchPause(ctx, "raccoon/raccoon-pause"))
+
+	assert.ElementsMatch(t, []string{"ns0", "ns2"}, k8sClient.PausedNamespaces())
+}
+
+func TestWatchPauseWrongConfigMap(t *testing.T) {
+	t.Parallel()
+
+	k8sClient := InitKubernetesClient(testclient.NewSimpleClientset())
+	assert.NotNil(t, k8sClient.WatchPause(context.Background(), "raccoon-pause"))
+
+	paused, _ := k8sClient.Paused("ns1")
+	assert.False(t, paused)
+}
+
+func TestPauseIsWatched(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientSet := testclient.NewSimpleClientset()
+	k8sClient := InitKubernetesClient(clientSet)
+	assert.Nil(t, k8sClient.WatchPause(ctx, "raccoon/raccoon-pause"))
+
+	paused, _ := k8sClient.Paused("")
+	assert.False(t, paused)
+
+	_, err := clientSet.CoreV1().ConfigMaps("raccoon").Create(ctx, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "raccoon-pause", Namespace: "raccoon"},
+		Data:       map[string]string{pausedKey: "true"},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		paused, _ := k8sClient.Paused("")
+		return paused
+	}, 5*time.Second, 10*time.Millisecond)
+}
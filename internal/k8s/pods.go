@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"time"
 
@@ -9,23 +10,63 @@ import (
 	v1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+const (
+	// AgeSourceCreation measures the age of a pod from its creation.
+	AgeSourceCreation = "creation"
+	// AgeSourceStartTime measures the age of a pod from its scheduling, time spent pending is ignored.
+	AgeSourceStartTime = "startTime"
+	// AgeSourceOldestContainerStart measures the age of a pod from its oldest running container start,
+	// so restarted containers are taken into account.
+	AgeSourceOldestContainerStart = "oldest-container-start"
+)
+
+const (
+	// SkipAnnotation excludes a pod from collection when set to "true".
+	SkipAnnotation = "backmarket.com/raccoon-skip"
+	// SnoozeUntilAnnotation postpones the collection of a pod until the given date (RFC3339).
+	SnoozeUntilAnnotation = "backmarket.com/raccoon-snooze-until"
+	// EvictAtAnnotation tells the pod when it is scheduled to be collected (RFC3339).
+	EvictAtAnnotation = "backmarket.com/raccoon-evict-at"
+
+	// SkipReasonOptedOut is the reason why a pod annotated to be skipped isn't collected.
+	SkipReasonOptedOut = "opted-out"
+	// SkipReasonSnoozed is the reason why a snoozed pod isn't collected until its snooze date.
+	SkipReasonSnoozed = "snoozed"
 )
 
 type KubernetesClient struct {
 	clientSet kubernetes.Interface
+	// metrics reads the metrics.k8s.io api, nil when it isn't used.
+	metrics metricsclient.Interface
+	// metadata reads the metadata of ConfigMaps and Secrets, nil when it isn't used.
+	metadata metadata.Interface
+	cache    *metaCache
+	pause    *pauseWatcher
 }
 
 // InitKubernetesClient inits a KubernetesClient.
 func InitKubernetesClient(clientSet kubernetes.Interface) *KubernetesClient {
-	return &KubernetesClient{clientSet: clientSet}
+	return &KubernetesClient{
+		clientSet: clientSet,
+		cache:     newMetaCache(ownerCacheTTL),
+	}
 }
 
 // ListPods returns a list of pods corresponding to the parameters you set.
 // Pods are sorted by age in descending order.
-func (k KubernetesClient) ListPods(ctx context.Context, namespace, labelSelector string) ([]v1.Pod, error) {
+func (k KubernetesClient) ListPods(ctx context.Context, namespace, labelSelector, fieldSelector string) ([]v1.Pod,
+	error) {
 	listOptions := metav1.ListOptions{
 		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
 	}
 	pods, err := k.clientSet.CoreV1().Pods(namespace).List(ctx, listOptions)
 	if err != nil {
@@ -37,17 +78,54 @@ func (k KubernetesClient) ListPods(ctx context.Context, namespace, labelSelector
 	return pods.Items, nil
 }
 
-// DeletePod deletes pods based on namespace & pod's name. Uses foreground deletion policy.
-func (k KubernetesClient) DeletePod(ctx context.Context, namespace, name string) error {
+// GetPod returns a pod based on namespace & pod's name.
+func (k KubernetesClient) GetPod(ctx context.Context, namespace, name string) (*v1.Pod, error) {
+	pod, err := k.clientSet.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pod")
+	}
+	return pod, nil
+}
+
+// SnoozePod postpones the collection of a pod until the given date.
+func (k KubernetesClient) SnoozePod(ctx context.Context, namespace, name string, until time.Time) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`,
+		SnoozeUntilAnnotation, until.UTC().Format(time.RFC3339)))
+	_, err := k.clientSet.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to snooze pod")
+	}
+	return nil
+}
+
+// AnnotateEvictAt writes the date a pod is scheduled to be collected at on the pod.
+func (k KubernetesClient) AnnotateEvictAt(ctx context.Context, namespace, name string, at time.Time) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`,
+		EvictAtAnnotation, at.UTC().Format(time.RFC3339)))
+	_, err := k.clientSet.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to annotate pod")
+	}
+	return nil
+}
+
+// DeletePod deletes pods based on namespace & pod's name, bypassing disruption budgets.
+// Uses foreground deletion policy, the uid precondition makes sure a pod recreated with the same name isn't deleted.
+func (k KubernetesClient) DeletePod(ctx context.Context, namespace, name string, uid types.UID,
+	gracePeriod time.Duration) error {
 	deleteFg := metav1.DeletePropagationForeground
+	gracePeriodSeconds := int64(gracePeriod.Seconds())
 
 	return k.clientSet.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{
-		PropagationPolicy: &deleteFg,
+		PropagationPolicy:  &deleteFg,
+		GracePeriodSeconds: &gracePeriodSeconds,
+		Preconditions:      metav1.NewUIDPreconditions(string(uid)),
 	})
 }
 
 // EvictPod evicts pods based on namespace & pod's name. Uses foreground deletion policy.
-func (k KubernetesClient) EvictPod(ctx context.Context, namespace, name string) error {
+// The uid precondition makes sure a pod recreated with the same name isn't evicted instead.
+func (k KubernetesClient) EvictPod(ctx context.Context, namespace, name string, uid types.UID) error {
 	deleteFg := metav1.DeletePropagationForeground
 
 	return k.clientSet.PolicyV1beta1().Evictions(namespace).Evict(ctx, &policy.Eviction{
@@ -57,6 +135,7 @@ func (k KubernetesClient) EvictPod(ctx context.Context, namespace, name string)
 		},
 		DeleteOptions: &metav1.DeleteOptions{
 			PropagationPolicy: &deleteFg,
+			Preconditions:     metav1.NewUIDPreconditions(string(uid)),
 		},
 	})
 }
@@ -67,6 +146,132 @@ func DateFromPodInSecond(pod v1.Pod) float64 {
 	return seconds
 }
 
+// ValidAgeSource reports whether the age source is supported.
+func ValidAgeSource(ageSource string) bool {
+	switch ageSource {
+	case AgeSourceCreation, AgeSourceStartTime, AgeSourceOldestContainerStart:
+		return true
+	default:
+		return false
+	}
+}
+
+// PodAge returns the age of a pod measured from the given age source.
+// A pod which hasn't started yet has no age, as well as a pod with no running container
+// when measuring from the oldest container start.
+func PodAge(pod v1.Pod, ageSource string, now time.Time) time.Duration {
+	var reference time.Time
+	switch ageSource {
+	case AgeSourceStartTime:
+		if pod.Status.StartTime != nil {
+			reference = pod.Status.StartTime.Time
+		}
+	case AgeSourceOldestContainerStart:
+		for _, status := range pod.Status.ContainerStatuses {
+			if running := status.State.Running; running != nil {
+				if reference.IsZero() || running.StartedAt.Time.Before(reference) {
+					reference = running.StartedAt.Time
+				}
+			}
+		}
+	default:
+		reference = pod.ObjectMeta.CreationTimestamp.Time
+	}
+	if reference.IsZero() {
+		return time.Duration(0)
+	}
+	return now.Sub(reference).Truncate(time.Second)
+}
+
+// OptedOut reports whether the pod is excluded from collection by its annotations,
+// either skipped or snoozed, along with the reason.
+// A malformed snooze date doesn't exclude the pod.
+func OptedOut(pod v1.Pod, now time.Time) (bool, string) {
+	annotations := pod.ObjectMeta.GetAnnotations()
+	if annotations[SkipAnnotation] == "true" {
+		return true, SkipReasonOptedOut
+	}
+	if snoozeUntil := annotations[SnoozeUntilAnnotation]; snoozeUntil != "" {
+		until, err := time.Parse(time.RFC3339, snoozeUntil)
+		if err == nil && now.Before(until) {
+			return true, SkipReasonSnoozed
+		}
+	}
+	return false, ""
+}
+
+// ControllerKind returns the kind of the pod's controller, empty for a bare pod.
+func ControllerKind(pod v1.Pod) string {
+	if controller := metav1.GetControllerOf(&pod); controller != nil {
+		return controller.Kind
+	}
+	return ""
+}
+
+// HasLocalStorage reports whether the pod mounts an emptyDir volume, whose data is lost on eviction.
+func HasLocalStorage(pod v1.Pod) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PodFields returns the fields of the pod which can be used in a field selector.
+func PodFields(pod v1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.ObjectMeta.Name,
+		"metadata.namespace":       pod.ObjectMeta.Namespace,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
+
+// ParseFieldSelector parses a pod field selector, only the fields returned by PodFields are supported.
+func ParseFieldSelector(fieldSelector string) (fields.Selector, error) {
+	selector, err := fields.ParseSelector(fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	supported := PodFields(v1.Pod{})
+	for _, requirement := range selector.Requirements() {
+		if !supported.Has(requirement.Field) {
+			return nil, fmt.Errorf("unsupported pod field %v", requirement.Field)
+		}
+	}
+	return selector, nil
+}
+
+// MatchesSelectors reports whether the pod matches both the label and the field selectors,
+// an empty selector matches every pod.
+func MatchesSelectors(pod v1.Pod, labelSelector, fieldSelector string) (bool, error) {
+	lSelector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid label selector")
+	}
+	fSelector, err := ParseFieldSelector(fieldSelector)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid field selector")
+	}
+	return lSelector.Matches(labels.Set(pod.ObjectMeta.Labels)) && fSelector.Matches(PodFields(pod)), nil
+}
+
+// IsPodReady reports whether the pod's Ready condition is true.
+func IsPodReady(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // Sort a list of v1.Pod by age in descending order.
 func sortPodByAgeDesc(pods *v1.PodList) *v1.PodList {
 	sort.Slice(pods.Items, func(i, j int) bool {
@@ -74,16 +279,3 @@ func sortPodByAgeDesc(pods *v1.PodList) *v1.PodList {
 	})
 	return pods
 }
-
-func TTLFromPod(pod v1.Pod, defaultTTL time.Duration) (time.Duration, error) {
-	if annotations := pod.ObjectMeta.GetAnnotations(); annotations != nil {
-		if ttlString := annotations["backmarket.com/raccoon-ttl"]; ttlString != "" {
-			ttl, err := time.ParseDuration(ttlString)
-			if err != nil {
-				return time.Duration(0), err
-			}
-			return ttl, nil
-		}
-	}
-	return defaultTTL, nil
-}
@@ -2,102 +2,17 @@ package k8s
 
 import (
 	"context"
-	"fmt"
 	"testing"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
-func TestTTLFromPod(t *testing.T) {
-	t.Parallel()
-
-	type unitData struct {
-		pod         v1.Pod
-		defaultTTL  time.Duration
-		expectedTTL time.Duration
-		err         error
-	}
-
-	data := map[string]unitData{
-		"use annotation 45s": {
-			pod: v1.Pod{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"backmarket.com/raccoon-ttl": "45s",
-					},
-				},
-			},
-			defaultTTL:  60 * time.Second,
-			expectedTTL: 45 * time.Second,
-			err:         nil,
-		},
-		"use annotation 1h": {
-			pod: v1.Pod{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"backmarket.com/raccoon-ttl": "1h",
-					},
-				},
-			},
-			defaultTTL:  60 * time.Second,
-			expectedTTL: 1 * time.Hour,
-			err:         nil,
-		},
-		"no annotation, default ttl": {
-			pod: v1.Pod{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"app": "test",
-					},
-				},
-			},
-			defaultTTL:  60 * time.Second,
-			expectedTTL: 60 * time.Second,
-			err:         nil,
-		},
-		"wrong annotation format": {
-			pod: v1.Pod{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"backmarket.com/raccoon-ttl": "wrong",
-					},
-				},
-			},
-			defaultTTL:  60 * time.Second,
-			expectedTTL: 0,
-			err:         fmt.Errorf("time: invalid duration \"wrong\""),
-		},
-	}
-
-	for name, unit := range data {
-		t.Run(name, func(unit unitData) func(t *testing.T) {
-			return func(t *testing.T) {
-
-				ttl, err := TTLFromPod(unit.pod, unit.defaultTTL)
-
-				if err != nil {
-					if unit.err == nil {
-						t.Fatalf(err.Error())
-					}
-
-					if err.Error() != unit.err.Error() {
-						t.Fatalf("expected err: %v, got: %v", unit.err.Error(), err.Error())
-					}
-				} else {
-
-					if ttl != unit.expectedTTL {
-						t.Fatalf("expected ttl: %v, got: %v", unit.expectedTTL, ttl)
-					}
-				}
-			}
-		}(unit))
-	}
-}
-
 func TestListPods(t *testing.T) {
 	t.Parallel()
 
@@ -166,7 +81,7 @@ func TestListPods(t *testing.T) {
 			return func(t *testing.T) {
 				k8sClient := InitKubernetesClient(unit.clientSet)
 
-				pods, _ := k8sClient.ListPods(context.Background(), unit.inputNamespace, unit.labelSelector)
+				pods, _ := k8sClient.ListPods(context.Background(), unit.inputNamespace, unit.labelSelector, "")
 
 				for i, pod := range pods {
 					if unit.sortedPodsName[i] != pod.Name {
@@ -178,3 +93,262 @@ func TestListPods(t *testing.T) {
 		}(unit))
 	}
 }
+
+func TestMatchesSelectors(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		labelSelector string
+		fieldSelector string
+		expected      bool
+		expectedErr   bool
+	}
+
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1", Labels: map[string]string{"app": "test", "tier": "db"}},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	data := map[string]unitData{
+		"empty selectors":        {expected: true},
+		"label selector":         {labelSelector: "app=test", expected: true},
+		"set based selector":     {labelSelector: "app in (test,other),tier,!canary", expected: true},
+		"set based mismatch":     {labelSelector: "tier notin (db)", expected: false},
+		"field selector":         {fieldSelector: "spec.nodeName=node-1,status.phase!=Pending", expected: true},
+		"field mismatch":         {labelSelector: "app=test", fieldSelector: "status.phase=Succeeded", expected: false},
+		"invalid label selector": {labelSelector: "app in test", expectedErr: true},
+		"unsupported field":      {fieldSelector: "spec.priority=1", expectedErr: true},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				matches, err := MatchesSelectors(pod, unit.labelSelector, unit.fieldSelector)
+
+				if (err != nil) != unit.expectedErr {
+					t.Fatalf("expected error: %v, got: %v", unit.expectedErr, err)
+				}
+				if matches != unit.expected {
+					t.Fatalf("expected: %v, got: %v", unit.expected, matches)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestPodAge(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod         v1.Pod
+		ageSource   string
+		expectedAge time.Duration
+	}
+
+	now := time.Date(2022, 6, 2, 12, 0, 0, 0, time.UTC)
+	startTime := metav1.NewTime(now.Add(-20 * time.Hour))
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+		},
+		Status: v1.PodStatus{
+			StartTime: &startTime,
+			ContainerStatuses: []v1.ContainerStatus{
+				{State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(now.Add(-time.Hour))}}},
+				{State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(now.Add(-2 * time.Hour))}}},
+				{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		},
+	}
+	pending := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+		},
+	}
+
+	data := map[string]unitData{
+		"creation":                {pod: pod, ageSource: AgeSourceCreation, expectedAge: 24 * time.Hour},
+		"start time":              {pod: pod, ageSource: AgeSourceStartTime, expectedAge: 20 * time.Hour},
+		"oldest container start":  {pod: pod, ageSource: AgeSourceOldestContainerStart, expectedAge: 2 * time.Hour},
+		"pending pod, creation":   {pod: pending, ageSource: AgeSourceCreation, expectedAge: 24 * time.Hour},
+		"pending pod, start time": {pod: pending, ageSource: AgeSourceStartTime, expectedAge: 0},
+		"pending pod, oldest container start": {
+			pod:         pending,
+			ageSource:   AgeSourceOldestContainerStart,
+			expectedAge: 0,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				age := PodAge(unit.pod, unit.ageSource, now)
+
+				if age != unit.expectedAge {
+					t.Fatalf("expected age: %v, got: %v", unit.expectedAge, age)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestOptedOut(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		annotations    map[string]string
+		expectedOptOut bool
+		expectedReason string
+	}
+
+	now := time.Date(2022, 6, 2, 12, 0, 0, 0, time.UTC)
+	data := map[string]unitData{
+		"no annotation":     {annotations: nil, expectedOptOut: false},
+		"skipped":           {annotations: map[string]string{SkipAnnotation: "true"}, expectedOptOut: true, expectedReason: SkipReasonOptedOut},
+		"skip false":        {annotations: map[string]string{SkipAnnotation: "false"}, expectedOptOut: false},
+		"snoozed":           {annotations: map[string]string{SnoozeUntilAnnotation: "2022-06-02T16:00:00Z"}, expectedOptOut: true, expectedReason: SkipReasonSnoozed},
+		"snooze over":       {annotations: map[string]string{SnoozeUntilAnnotation: "2022-06-02T10:00:00Z"}, expectedOptOut: false},
+		"wrong snooze date": {annotations: map[string]string{SnoozeUntilAnnotation: "tomorrow"}, expectedOptOut: false},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: unit.annotations}}
+
+				optedOut, reason := OptedOut(pod, now)
+
+				if optedOut != unit.expectedOptOut || reason != unit.expectedReason {
+					t.Fatalf("expected: %v %v, got: %v %v", unit.expectedOptOut, unit.expectedReason, optedOut, reason)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestControllerKind(t *testing.T) {
+	t.Parallel()
+
+	controller := true
+	owned := v1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{
+		{Kind: KindReplicaSet, Name: "rs-1"},
+		{Kind: KindJob, Name: "job-1", Controller: &controller},
+	}}}
+	if kind := ControllerKind(owned); kind != KindJob {
+		t.Fatalf("expected: %v, got: %v", KindJob, kind)
+	}
+	if kind := ControllerKind(v1.Pod{}); kind != "" {
+		t.Fatalf("expected no controller, got: %v", kind)
+	}
+}
+
+func TestHasLocalStorage(t *testing.T) {
+	t.Parallel()
+
+	pod := v1.Pod{Spec: v1.PodSpec{Volumes: []v1.Volume{
+		{Name: "token", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{}}},
+	}}}
+	if HasLocalStorage(pod) {
+		t.Fatal("expected no local storage")
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes,
+		v1.Volume{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}})
+	if !HasLocalStorage(pod) {
+		t.Fatal("expected local storage")
+	}
+}
+
+func TestSnoozePod(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+	until := time.Date(2022, 6, 2, 16, 0, 0, 0, time.UTC)
+
+	if err := k8sClient.SnoozePod(ctx, "ns1", "pod-1", until); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	pod, err := k8sClient.GetPod(ctx, "ns1", "pod-1")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if pod.Annotations[SnoozeUntilAnnotation] != "2022-06-02T16:00:00Z" {
+		t.Fatalf("expected snooze annotation, got: %v", pod.Annotations)
+	}
+}
+
+func TestAnnotateEvictAt(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+	at := time.Date(2026, 11, 1, 3, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	if err := k8sClient.AnnotateEvictAt(ctx, "ns1", "pod-1", at); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	pod, err := k8sClient.GetPod(ctx, "ns1", "pod-1")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if pod.Annotations[EvictAtAnnotation] != "2026-11-01T02:00:00Z" {
+		t.Fatalf("expected evict-at annotation, got: %v", pod.Annotations)
+	}
+}
+
+func TestEvictPodWithUIDPrecondition(t *testing.T) {
+	t.Parallel()
+
+	clientSet := testclient.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1", UID: "uid-1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+
+	if err := k8sClient.EvictPod(context.Background(), "ns1", "pod-1", "uid-1"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	actions := clientSet.Actions()
+	if len(actions) != 1 || actions[0].GetSubresource() != "eviction" {
+		t.Fatalf("expected an eviction, got: %v", actions)
+	}
+	eviction := actions[0].(k8stesting.CreateAction).GetObject().(*policy.Eviction)
+	if uid := eviction.DeleteOptions.Preconditions.UID; uid == nil || *uid != "uid-1" {
+		t.Fatalf("expected uid precondition: uid-1, got: %v", uid)
+	}
+}
+
+func TestDeletePodWithGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	clientSet := testclient.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns1", UID: "uid-1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+
+	if err := k8sClient.DeletePod(context.Background(), "ns1", "pod-1", "uid-1", 30*time.Second); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	actions := clientSet.Actions()
+	if len(actions) != 1 || actions[0].GetVerb() != "delete" {
+		t.Fatalf("expected a deletion, got: %v", actions)
+	}
+	opts := actions[0].(k8stesting.DeleteActionImpl).DeleteOptions
+	if opts.GracePeriodSeconds == nil || *opts.GracePeriodSeconds != 30 {
+		t.Fatalf("expected grace period: 30, got: %v", opts.GracePeriodSeconds)
+	}
+	if uid := opts.Preconditions.UID; uid == nil || *uid != "uid-1" {
+		t.Fatalf("expected uid precondition: uid-1, got: %v", uid)
+	}
+}
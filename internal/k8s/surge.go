@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// SurgeAnnotation keeps, on a surged Deployment or on its HorizontalPodAutoscaler,
+	// the values to restore once the surge is over.
+	SurgeAnnotation = "backmarket.com/raccoon-surge"
+)
+
+// Surge is a Deployment scaled up by one replica, before evicting one of its pods.
+type Surge struct {
+	Owner Owner
+	// HPA is the autoscaler managing the Deployment, empty when there is none.
+	// Its min replicas are raised instead of the Deployment's replicas, so it doesn't scale the surge down.
+	HPA string
+	// Target is the number of ready replicas expected during the surge.
+	Target int32
+	// Original are the values to restore once the surge is over.
+	Original SurgeOriginal
+}
+
+// SurgeOriginal are the replicas of a Deployment, or the bounds of its autoscaler, before a surge.
+type SurgeOriginal struct {
+	Replicas    int32 `json:"replicas,omitempty"`
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+}
+
+// SurgeWorkload scales the Deployment up by one replica, through its autoscaler when it has one.
+// The original values are annotated on the scaled object, so a surge interrupted before being restored,
+// e.g. by a restart of raccoon, isn't surged once more but still restored to the original values.
+func (k KubernetesClient) SurgeWorkload(ctx context.Context, owner Owner) (Surge, error) {
+	if owner.Kind != KindDeployment {
+		return Surge{}, fmt.Errorf("k8s: unsupported owner kind for surge, %v", owner.Kind)
+	}
+	hpa, err := k.ownerHPA(ctx, owner)
+	if err != nil {
+		return Surge{}, err
+	}
+	if hpa != nil {
+		return k.surgeHPA(ctx, owner, hpa)
+	}
+	return k.surgeDeployment(ctx, owner)
+}
+
+// surgeHPA raises the bounds of the Deployment's autoscaler to one replica above its desired replicas.
+func (k KubernetesClient) surgeHPA(ctx context.Context, owner Owner,
+	hpa *autoscalingv2.HorizontalPodAutoscaler) (Surge, error) {
+	surge := Surge{Owner: owner, HPA: hpa.Name}
+	surge.Original = SurgeOriginal{MinReplicas: replicasOrDefault(hpa.Spec.MinReplicas),
+		MaxReplicas: hpa.Spec.MaxReplicas}
+	interrupted, err := originalFromAnnotations(hpa.Annotations, &surge.Original)
+	if err != nil {
+		return Surge{}, err
+	}
+	current := hpa.Status.DesiredReplicas
+	if current < surge.Original.MinReplicas {
+		current = surge.Original.MinReplicas
+	}
+	surge.Target = current + 1
+	if interrupted {
+		// the autoscaler's desired replicas already include the interrupted surge
+		surge.Target = replicasOrDefault(hpa.Spec.MinReplicas)
+	}
+	maxReplicas := surge.Original.MaxReplicas
+	if maxReplicas < surge.Target {
+		maxReplicas = surge.Target
+	}
+	patch, err := surgePatch(surge.Original, fmt.Sprintf(`"spec":{"minReplicas":%d,"maxReplicas":%d}`,
+		surge.Target, maxReplicas))
+	if err != nil {
+		return Surge{}, err
+	}
+	_, err = k.clientSet.AutoscalingV2().HorizontalPodAutoscalers(owner.Namespace).Patch(ctx, hpa.Name,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return Surge{}, errors.Wrap(err, "failed to surge horizontal pod autoscaler")
+	}
+	return surge, nil
+}
+
+// surgeDeployment scales the Deployment up by one replica.
+func (k KubernetesClient) surgeDeployment(ctx context.Context, owner Owner) (Surge, error) {
+	surge := Surge{Owner: owner}
+	deployment, err := k.clientSet.AppsV1().Deployments(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	if err != nil {
+		return Surge{}, errors.Wrap(err, "failed to get deployment")
+	}
+	surge.Original = SurgeOriginal{Replicas: replicasOrDefault(deployment.Spec.Replicas)}
+	if _, err := originalFromAnnotations(deployment.Annotations, &surge.Original); err != nil {
+		return Surge{}, err
+	}
+	surge.Target = surge.Original.Replicas + 1
+	patch, err := surgePatch(surge.Original, fmt.Sprintf(`"spec":{"replicas":%d}`, surge.Target))
+	if err != nil {
+		return Surge{}, err
+	}
+	_, err = k.clientSet.AppsV1().Deployments(owner.Namespace).Patch(ctx, owner.Name,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return Surge{}, errors.Wrap(err, "failed to surge deployment")
+	}
+	return surge, nil
+}
+
+// RestoreSurge restores the replicas of a surged Deployment, or the bounds of its autoscaler.
+func (k KubernetesClient) RestoreSurge(ctx context.Context, surge Surge) error {
+	if surge.HPA != "" {
+		patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}},"spec":{"minReplicas":%d,"maxReplicas":%d}}`,
+			SurgeAnnotation, surge.Original.MinReplicas, surge.Original.MaxReplicas))
+		_, err := k.clientSet.AutoscalingV2().HorizontalPodAutoscalers(surge.Owner.Namespace).Patch(ctx, surge.HPA,
+			types.MergePatchType, patch, metav1.PatchOptions{})
+		return errors.Wrap(err, "failed to restore horizontal pod autoscaler")
+	}
+
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}},"spec":{"replicas":%d}}`,
+		SurgeAnnotation, surge.Original.Replicas))
+	_, err := k.clientSet.AppsV1().Deployments(surge.Owner.Namespace).Patch(ctx, surge.Owner.Name,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	return errors.Wrap(err, "failed to restore deployment")
+}
+
+// ownerHPA returns the autoscaler targeting the owner, nil when there is none.
+func (k KubernetesClient) ownerHPA(ctx context.Context, owner Owner) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	hpas, err := k.clientSet.AutoscalingV2().HorizontalPodAutoscalers(owner.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list horizontal pod autoscalers")
+	}
+	for i, hpa := range hpas.Items {
+		if hpa.Spec.ScaleTargetRef.Kind == owner.Kind && hpa.Spec.ScaleTargetRef.Name == owner.Name {
+			return &hpas.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// originalFromAnnotations reads the values annotated by an interrupted surge, it reports whether there is one.
+func originalFromAnnotations(annotations map[string]string, original *SurgeOriginal) (bool, error) {
+	value, ok := annotations[SurgeAnnotation]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(value), original); err != nil {
+		return false, errors.Wrapf(err, "invalid %s annotation", SurgeAnnotation)
+	}
+	return true, nil
+}
+
+// surgePatch builds a merge patch annotating the original values along with the given spec.
+func surgePatch(original SurgeOriginal, spec string) ([]byte, error) {
+	value, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},%s}`, SurgeAnnotation, value, spec)), nil
+}
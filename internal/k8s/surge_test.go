@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSurgeDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	replicas := int32(2)
+	clientSet := testclient.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "ns1"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+	owner := Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"}
+
+	surge, err := k8sClient.SurgeWorkload(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), surge.Target)
+	deployment, _ := clientSet.AppsV1().Deployments("ns1").Get(ctx, "app-1", metav1.GetOptions{})
+	assert.Equal(t, int32(3), *deployment.Spec.Replicas)
+	assert.Equal(t, `{"replicas":2}`, deployment.Annotations[SurgeAnnotation])
+
+	// an interrupted surge isn't surged once more
+	surge, err = k8sClient.SurgeWorkload(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), surge.Target)
+
+	assert.Nil(t, k8sClient.RestoreSurge(ctx, surge))
+	deployment, _ = clientSet.AppsV1().Deployments("ns1").Get(ctx, "app-1", metav1.GetOptions{})
+	assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+	assert.NotContains(t, deployment.Annotations, SurgeAnnotation)
+}
+
+func TestSurgeAutoscaledDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	minReplicas := int32(2)
+	clientSet := testclient.NewSimpleClientset(&autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "ns1"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: KindDeployment, Name: "app-1"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    3,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{DesiredReplicas: 3},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+	owner := Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"}
+
+	surge, err := k8sClient.SurgeWorkload(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, "app-1", surge.HPA)
+	assert.Equal(t, int32(4), surge.Target)
+	hpa, _ := clientSet.AutoscalingV2().HorizontalPodAutoscalers("ns1").Get(ctx, "app-1", metav1.GetOptions{})
+	assert.Equal(t, int32(4), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(4), hpa.Spec.MaxReplicas)
+
+	assert.Nil(t, k8sClient.RestoreSurge(ctx, surge))
+	hpa, _ = clientSet.AutoscalingV2().HorizontalPodAutoscalers("ns1").Get(ctx, "app-1", metav1.GetOptions{})
+	assert.Equal(t, int32(2), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(3), hpa.Spec.MaxReplicas)
+	assert.NotContains(t, hpa.Annotations, SurgeAnnotation)
+}
@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// StaleTemplate reports whether the pod differs from the current template of its owner, a Deployment, a StatefulSet
+// or a DaemonSet: the pod belongs to a revision older than the current one of its owner, or runs other images than
+// the template. The pods of a ReplicaSet orphaned by its Deployment are compared with the Deployment selecting them.
+// Pods without such an owner, or of a StatefulSet with a partitioned rollout, are never stale. The owner is read from
+// the api rather than from the cache, a cached owner may predate a rollout and flag its new pods as stale.
+func (k KubernetesClient) StaleTemplate(ctx context.Context, pod v1.Pod) (bool, error) {
+	chain, err := k.ownerChain(ctx, pod)
+	if err != nil {
+		return false, err
+	}
+	for i, owner := range chain {
+		switch owner.Kind {
+		case KindDeployment, KindStatefulSet, KindDaemonSet:
+			workload, err := k.getObject(ctx, owner.Owner)
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to get owner %v", owner.Owner)
+			}
+			return k.staleFor(ctx, pod, workload, chain[:i])
+		}
+	}
+	if len(chain) == 1 && chain[0].Kind == KindReplicaSet && metav1.GetControllerOf(chain[0].meta) == nil {
+		return k.staleOrphan(ctx, pod)
+	}
+	return false, nil
+}
+
+// staleFor reports whether the pod is stale for its workload, owned are the owners between the pod and the workload.
+func (k KubernetesClient) staleFor(ctx context.Context, pod v1.Pod, workload metav1.Object,
+	owned []ownerObject) (bool, error) {
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		if len(owned) == 0 {
+			return false, nil
+		}
+		return k.staleDeploymentPod(ctx, pod, workload)
+	case *appsv1.StatefulSet:
+		return staleRevision(pod, workload), nil
+	case *appsv1.DaemonSet:
+		return imagesDiffer(pod.Spec, workload.Spec.Template.Spec), nil
+	}
+	return false, nil
+}
+
+// staleOrphan reports whether the pod of an orphaned ReplicaSet is stale for the Deployment selecting it.
+// Pods selected by no Deployment are never stale.
+func (k KubernetesClient) staleOrphan(ctx context.Context, pod v1.Pod) (bool, error) {
+	deployments, err := k.clientSet.AppsV1().Deployments(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list deployments")
+	}
+	for i := range deployments.Items {
+		selector, err := metav1.LabelSelectorAsSelector(deployments.Items[i].Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		return k.staleDeploymentPod(ctx, pod, &deployments.Items[i])
+	}
+	return false, nil
+}
+
+// staleDeploymentPod reports whether the pod runs another template than the current one of its Deployment:
+// its pod-template-hash isn't the one of the Deployment's ReplicaSet having the current template. The ReplicaSets are
+// read from the api too, as a rollback gives the current template back to a previous ReplicaSet.
+// A template edited while the Deployment is paused has no ReplicaSet, the pod's images are compared with the
+// template's. Otherwise the ReplicaSet is about to be created by the rollout, the pod isn't stale yet.
+func (k KubernetesClient) staleDeploymentPod(ctx context.Context, pod v1.Pod,
+	deployment *appsv1.Deployment) (bool, error) {
+	replicaSets, err := k.clientSet.AppsV1().ReplicaSets(deployment.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list replicasets")
+	}
+	for i := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[i]
+		ref := metav1.GetControllerOf(replicaSet)
+		if ref == nil || ref.Kind != KindDeployment || ref.Name != deployment.Name ||
+			!sameTemplate(replicaSet.Spec.Template, deployment.Spec.Template) {
+			continue
+		}
+		hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		return hash != "" && hash != replicaSet.Labels[appsv1.DefaultDeploymentUniqueLabelKey], nil
+	}
+	if !deployment.Spec.Paused {
+		return false, nil
+	}
+	return imagesDiffer(pod.Spec, deployment.Spec.Template.Spec), nil
+}
+
+// sameTemplate reports whether a ReplicaSet's template is the Deployment's one, the pod-template-hash label aside,
+// the same way the deployment controller looks for the ReplicaSet of the current template.
+func sameTemplate(replicaSet, deployment v1.PodTemplateSpec) bool {
+	replicaSetCopy, deploymentCopy := replicaSet.DeepCopy(), deployment.DeepCopy()
+	delete(replicaSetCopy.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	delete(deploymentCopy.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return apiequality.Semantic.DeepEqual(replicaSetCopy, deploymentCopy)
+}
+
+// staleRevision reports whether the pod doesn't belong to the update revision of its StatefulSet, the newest one.
+// Pods below the partition are kept at their revision on purpose, and a status which hasn't observed the current
+// spec yet may not have its update revision.
+func staleRevision(pod v1.Pod, statefulSet *appsv1.StatefulSet) bool {
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil &&
+		rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		return false
+	}
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+		return false
+	}
+	revision := pod.Labels[appsv1.ControllerRevisionHashLabelKey]
+	return revision != "" && statefulSet.Status.UpdateRevision != "" && revision != statefulSet.Status.UpdateRevision
+}
+
+// imagesDiffer reports whether a container of the template runs another image in the pod.
+// Containers missing from the template, e.g. injected sidecars, are ignored.
+func imagesDiffer(pod, template v1.PodSpec) bool {
+	images := make(map[string]string, len(pod.InitContainers)+len(pod.Containers))
+	for _, containers := range [][]v1.Container{pod.InitContainers, pod.Containers} {
+		for _, container := range containers {
+			images[container.Name] = container.Image
+		}
+	}
+	for _, containers := range [][]v1.Container{template.InitContainers, template.Containers} {
+		for _, container := range containers {
+			if image, ok := images[container.Name]; !ok || !sameImage(image, container.Image) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageReference is a container image split into its repository, tag and digest.
+type imageReference struct {
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImage splits an image into its repository, tag and digest. The registry is left out, as well as the library
+// namespace of the default registry, so an image rewritten to a registry mirror keeps its repository.
+func parseImage(image string) imageReference {
+	var ref imageReference
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, ref.digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.tag = image[:i], image[i+1:]
+	}
+	if i := strings.Index(image, "/"); i >= 0 && (strings.ContainsAny(image[:i], ".:") || image[:i] == "localhost") {
+		image = image[i+1:]
+	}
+	ref.repository = strings.TrimPrefix(image, "library/")
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+	return ref
+}
+
+// sameImage reports whether two images may be the same, once normalized. Admission webhooks rewrite images,
+// pinning tags to digests or pointing to a registry mirror, so the tags and the digests are only compared when
+// both images have one, and a repository matches a mirror's repository ending with it.
+func sameImage(a, b string) bool {
+	refA, refB := parseImage(a), parseImage(b)
+	if refA.repository != refB.repository && !strings.HasSuffix(refA.repository, "/"+refB.repository) &&
+		!strings.HasSuffix(refB.repository, "/"+refA.repository) {
+		return false
+	}
+	if refA.digest != "" && refB.digest != "" {
+		return refA.digest == refB.digest
+	}
+	return refA.tag == "" || refB.tag == "" || refA.tag == refB.tag
+}
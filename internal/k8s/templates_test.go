@@ -0,0 +1,259 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+// deploymentTemplate is the template of a Deployment, its pods labeled with the Deployment's name.
+func deploymentTemplate(app, image string) v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": app}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: image}}},
+	}
+}
+
+// deploymentReplicaSet is a ReplicaSet of the Deployment's template running the image, orphaned unless controlled.
+func deploymentReplicaSet(name, deployment, hash, image string, controlled bool) *appsv1.ReplicaSet {
+	template := deploymentTemplate(deployment, image)
+	template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = hash
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1", Labels: template.Labels},
+		Spec:       appsv1.ReplicaSetSpec{Template: template},
+	}
+	if controlled {
+		replicaSet.OwnerReferences = controllerRef(KindDeployment, deployment)
+	}
+	return replicaSet
+}
+
+func TestStaleTemplate(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod      v1.Pod
+		expected bool
+	}
+
+	podSpec := func(image string) v1.PodSpec {
+		return v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: image}}}
+	}
+	deployment := func(name, image string, paused bool) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: deploymentTemplate(name, image),
+				Paused:   paused,
+			},
+		}
+	}
+	deploymentPod := func(replicaSet, app, hash, image string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindReplicaSet, replicaSet),
+				Labels: map[string]string{"app": app, appsv1.DefaultDeploymentUniqueLabelKey: hash}},
+			Spec: podSpec(image),
+		}
+	}
+	partition := int32(2)
+	clientSet := testclient.NewSimpleClientset(
+		deployment("app-1", "app:2", false),
+		deploymentReplicaSet("app-1-new", "app-1", "new", "app:2", true),
+		deploymentReplicaSet("app-1-old", "app-1", "old", "app:1", true),
+		deployment("app-2", "app:1", false),
+		deploymentReplicaSet("app-2-1", "app-2", "1", "app:1", true),
+		deploymentReplicaSet("app-2-2", "app-2", "2", "app:2", true),
+		deployment("app-3", "app:3", false),
+		deploymentReplicaSet("app-3-old", "app-3", "old", "app:2", true),
+		deployment("app-4", "app:2", true),
+		deploymentReplicaSet("app-4-old", "app-4", "old", "app:1", true),
+		deployment("app-5", "app:2", false),
+		deploymentReplicaSet("app-5-new", "app-5", "new", "app:2", true),
+		deploymentReplicaSet("app-5-orphan", "app-5", "orphan", "app:1", false),
+		deploymentReplicaSet("other-orphan", "other", "orphan", "other:1", false),
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-1", Namespace: "ns1"},
+			Spec:       appsv1.StatefulSetSpec{Template: v1.PodTemplateSpec{Spec: podSpec("db:2")}},
+			Status:     appsv1.StatefulSetStatus{UpdateRevision: "db-1-new"},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-3", Namespace: "ns1", Generation: 2},
+			Status:     appsv1.StatefulSetStatus{ObservedGeneration: 1, UpdateRevision: "db-3-old"},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-2", Namespace: "ns1"},
+			Spec: appsv1.StatefulSetSpec{
+				Template: v1.PodTemplateSpec{Spec: podSpec("db:2")},
+				UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+					RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+				},
+			},
+			Status: appsv1.StatefulSetStatus{UpdateRevision: "db-2-new"},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent-1", Namespace: "ns1"},
+			Spec:       appsv1.DaemonSetSpec{Template: v1.PodTemplateSpec{Spec: podSpec("agent:2")}},
+		},
+	)
+	k8sClient := InitKubernetesClient(clientSet)
+
+	data := map[string]unitData{
+		"deployment's current replicaset": {
+			pod: deploymentPod("app-1-new", "app-1", "new", "app:2"),
+		},
+		"deployment's previous replicaset": {
+			pod:      deploymentPod("app-1-old", "app-1", "old", "app:1"),
+			expected: true,
+		},
+		"deployment rolled back to its previous replicaset": {
+			pod:      deploymentPod("app-2-2", "app-2", "2", "app:2"),
+			expected: true,
+		},
+		"deployment rolled back, pod of the replicaset rolled back to": {
+			pod: deploymentPod("app-2-1", "app-2", "1", "app:1"),
+		},
+		"deployment's template changed, its replicaset not created yet": {
+			pod: deploymentPod("app-3-old", "app-3", "old", "app:2"),
+		},
+		"paused deployment's template changed": {
+			pod:      deploymentPod("app-4-old", "app-4", "old", "app:1"),
+			expected: true,
+		},
+		"orphaned replicaset selected by a deployment": {
+			pod:      deploymentPod("app-5-orphan", "app-5", "orphan", "app:1"),
+			expected: true,
+		},
+		"orphaned replicaset selected by no deployment": {
+			pod: deploymentPod("other-orphan", "other", "orphan", "other:1"),
+		},
+		"injected sidecar": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindReplicaSet, "app-1-new"),
+					Labels: map[string]string{"app": "app-1", appsv1.DefaultDeploymentUniqueLabelKey: "new"}},
+				Spec: v1.PodSpec{Containers: []v1.Container{
+					{Name: "app", Image: "app:2"},
+					{Name: "proxy", Image: "proxy:1"},
+				}},
+			},
+		},
+		"statefulset's previous revision": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindStatefulSet, "db-1"),
+					Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: "db-1-old"}},
+				Spec: podSpec("db:2"),
+			},
+			expected: true,
+		},
+		"statefulset with a partitioned rollout": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindStatefulSet, "db-2"),
+					Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: "db-2-old"}},
+				Spec: podSpec("db:1"),
+			},
+		},
+		"statefulset's spec not observed yet": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindStatefulSet, "db-3"),
+					Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: "db-3-new"}},
+			},
+		},
+		"daemonset's image pinned to its digest from a mirror": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindDaemonSet, "agent-1")},
+				Spec:       podSpec("mirror.example.com/dockerhub/library/agent:2@sha256:4b5e"),
+			},
+		},
+		"daemonset's outdated image": {
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", OwnerReferences: controllerRef(KindDaemonSet, "agent-1")},
+				Spec:       podSpec("agent:1"),
+			},
+			expected: true,
+		},
+		"bare pod": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1"}, Spec: podSpec("app:1")},
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				stale, err := k8sClient.StaleTemplate(context.Background(), unit.pod)
+				assert.NoError(t, err)
+				assert.Equal(t, unit.expected, stale)
+			}
+		}(unit))
+	}
+}
+
+func TestStaleTemplateAfterRollout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "ns1"},
+		Spec:       appsv1.DeploymentSpec{Template: deploymentTemplate("app-1", "app:1")},
+	}
+	clientSet := testclient.NewSimpleClientset(deployment,
+		deploymentReplicaSet("app-1-old", "app-1", "old", "app:1", true))
+	k8sClient := InitKubernetesClient(clientSet)
+	oldPod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1",
+		OwnerReferences: controllerRef(KindReplicaSet, "app-1-old"),
+		Labels:          map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "old"}}}
+
+	// the deployment is cached with its first template
+	stale, err := k8sClient.StaleTemplate(ctx, oldPod)
+	assert.NoError(t, err)
+	assert.False(t, stale)
+
+	// rollout to the second template
+	deployment.Spec.Template = deploymentTemplate("app-1", "app:2")
+	_, err = clientSet.AppsV1().Deployments("ns1").Update(ctx, deployment, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	_, err = clientSet.AppsV1().ReplicaSets("ns1").Create(ctx,
+		deploymentReplicaSet("app-1-new", "app-1", "new", "app:2", true), metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	stale, err = k8sClient.StaleTemplate(ctx, v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1",
+		OwnerReferences: controllerRef(KindReplicaSet, "app-1-new"),
+		Labels:          map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "new"}}})
+	assert.NoError(t, err)
+	assert.False(t, stale, "new pods aren't stale")
+	stale, err = k8sClient.StaleTemplate(ctx, oldPod)
+	assert.NoError(t, err)
+	assert.True(t, stale, "previous pods are stale")
+}
+
+func TestSameImage(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]struct {
+		a, b     string
+		expected bool
+	}{
+		"same tag":             {a: "app:2", b: "app:2", expected: true},
+		"other tag":            {a: "app:1", b: "app:2"},
+		"default tag":          {a: "app", b: "app:latest", expected: true},
+		"default registry":     {a: "docker.io/library/app:2", b: "app:2", expected: true},
+		"tag pinned to digest": {a: "app:2@sha256:4b5e", b: "app:2", expected: true},
+		"digest only":          {a: "app@sha256:4b5e", b: "app:2", expected: true},
+		"other digest":         {a: "app@sha256:4b5e", b: "app@sha256:9f3a"},
+		"registry mirror":      {a: "mirror.example.com/dockerhub/team/app:2", b: "team/app:2", expected: true},
+		"registry with port":   {a: "localhost:5000/app:2", b: "app:2", expected: true},
+		"other repository":     {a: "team/app:2", b: "other/app:2"},
+	}
+
+	for name, unit := range data {
+		if same := sameImage(unit.a, unit.b); same != unit.expected {
+			t.Errorf("%s: expected: %v, got: %v", name, unit.expected, same)
+		}
+	}
+}
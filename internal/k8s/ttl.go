@@ -0,0 +1,242 @@
+package k8s
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ExpiresAtAnnotation sets an absolute expiry date (RFC3339), on a pod, its owners or its namespace.
+	ExpiresAtAnnotation = "backmarket.com/raccoon-expires-at"
+	// AgeSourceAnnotation overrides the default age source, on a pod, its owners or its namespace.
+	AgeSourceAnnotation = "backmarket.com/raccoon-age-source"
+	// MaxRestartsAnnotation overrides the default max restarts, on a pod, its owners or its namespace.
+	MaxRestartsAnnotation = "backmarket.com/raccoon-max-restarts"
+	// OOMKillWindowAnnotation overrides the default OOM kill window, on a pod, its owners or its namespace.
+	OOMKillWindowAnnotation = "backmarket.com/raccoon-oom-kill-window"
+
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// longUnits matches the units time.ParseDuration doesn't support, e.g. "7d" or "1.5w".
+var longUnits = regexp.MustCompile(`([0-9]*\.?[0-9]+)([dw])`)
+
+// Expiration describes when a pod must be collected.
+type Expiration struct {
+	// TTL is the maximum age of the pod, jitter included.
+	TTL time.Duration
+	// ExpiresAt is an absolute expiry date, zero when not set.
+	// It only applies to pods created before this date.
+	ExpiresAt time.Time
+	// AgeSource is the reference the pod's age is measured from, empty when not annotated.
+	AgeSource string
+	// MaxRestarts is the number of restarts from which the pod is collected, nil when not annotated.
+	MaxRestarts *int
+	// OOMKillWindow is the window during which an OOM kill collects the pod, nil when not annotated.
+	OOMKillWindow *time.Duration
+}
+
+// Age returns the age of the pod, measured from the annotated age source or else from the default one.
+func (e Expiration) Age(pod v1.Pod, defaultAgeSource string, now time.Time) time.Duration {
+	ageSource := e.AgeSource
+	if ageSource == "" {
+		ageSource = defaultAgeSource
+	}
+	return PodAge(pod, ageSource, now)
+}
+
+// MaxRestartsOrDefault returns the annotated max restarts, or else the default one.
+func (e Expiration) MaxRestartsOrDefault(defaultMaxRestarts int) int {
+	if e.MaxRestarts == nil {
+		return defaultMaxRestarts
+	}
+	return *e.MaxRestarts
+}
+
+// OOMKillWindowOrDefault returns the annotated OOM kill window, or else the default one.
+func (e Expiration) OOMKillWindowOrDefault(defaultWindow time.Duration) time.Duration {
+	if e.OOMKillWindow == nil {
+		return defaultWindow
+	}
+	return *e.OOMKillWindow
+}
+
+// Expired reports whether a pod of the given age must be collected.
+func (e Expiration) Expired(pod v1.Pod, age time.Duration, now time.Time) bool {
+	if age > e.TTL {
+		return true
+	}
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt) &&
+		pod.ObjectMeta.CreationTimestamp.Time.Before(e.ExpiresAt)
+}
+
+// Overdue returns for how long a pod of the given age has been expired, 0 when it isn't expired.
+func (e Expiration) Overdue(pod v1.Pod, age time.Duration, now time.Time) time.Duration {
+	var overdue time.Duration
+	if age > e.TTL {
+		overdue = age - e.TTL
+	}
+	if !e.ExpiresAt.IsZero() && pod.ObjectMeta.CreationTimestamp.Time.Before(e.ExpiresAt) &&
+		now.Sub(e.ExpiresAt) > overdue {
+		overdue = now.Sub(e.ExpiresAt)
+	}
+	return overdue
+}
+
+// Remaining returns how long before a pod of the given age expires, 0 when it is expired.
+func (e Expiration) Remaining(pod v1.Pod, age time.Duration, now time.Time) time.Duration {
+	if e.Expired(pod, age, now) {
+		return 0
+	}
+	remaining := e.TTL - age
+	if !e.ExpiresAt.IsZero() && pod.ObjectMeta.CreationTimestamp.Time.Before(e.ExpiresAt) &&
+		e.ExpiresAt.Sub(now) < remaining {
+		remaining = e.ExpiresAt.Sub(now)
+	}
+	return remaining
+}
+
+// ttlSpec is a parsed ttl annotation, e.g. "45m", "7d", "1w2d" or "24h±2h".
+type ttlSpec struct {
+	base   time.Duration
+	jitter time.Duration
+}
+
+// parseTTL parses a positive duration, optionally followed by a jitter range introduced by "±" or "+-".
+func parseTTL(value string) (ttlSpec, error) {
+	parts := strings.SplitN(strings.ReplaceAll(value, "+-", "±"), "±", 2)
+	base, err := parseDuration(parts[0])
+	if err != nil {
+		return ttlSpec{}, err
+	}
+	if base <= 0 {
+		return ttlSpec{}, fmt.Errorf("invalid ttl %q, it must be positive", value)
+	}
+	spec := ttlSpec{base: base}
+	if len(parts) == 2 {
+		spec.jitter, err = parseDuration(parts[1])
+		if err != nil {
+			return ttlSpec{}, err
+		}
+		if spec.jitter < 0 || spec.jitter >= spec.base {
+			return ttlSpec{}, fmt.Errorf("invalid ttl jitter %q, it must be between 0 and the ttl, excluded", value)
+		}
+	}
+	return spec, nil
+}
+
+// parseDuration extends time.ParseDuration with days (d) and weeks (w) units, spaces are ignored (e.g. "1d 2h").
+// Durations with days or weeks can't be signed, the sign would only apply to their shorter units.
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.Join(strings.Fields(value), "")
+	if longUnits.MatchString(value) && strings.ContainsAny(value, "+-") {
+		return time.Duration(0), fmt.Errorf("time: invalid duration %q", value)
+	}
+	var long time.Duration
+	rest := longUnits.ReplaceAllStringFunc(value, func(match string) string {
+		groups := longUnits.FindStringSubmatch(match)
+		n, _ := strconv.ParseFloat(groups[1], 64)
+		unit := day
+		if groups[2] == "w" {
+			unit = week
+		}
+		long += time.Duration(n * float64(unit))
+		return ""
+	})
+	if rest == "" && rest != value {
+		return long, nil
+	}
+	short, err := time.ParseDuration(rest)
+	if err != nil {
+		return time.Duration(0), fmt.Errorf("time: invalid duration %q", value)
+	}
+	return long + short, nil
+}
+
+// forPod applies the jitter to the ttl. The offset is derived from the pod's uid,
+// so it is stable across checks while pods created together get different ttls.
+func (t ttlSpec) forPod(uid types.UID) time.Duration {
+	jitterSeconds := int64(t.jitter / time.Second)
+	if jitterSeconds == 0 {
+		return t.base
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(uid))
+	offset := int64(h.Sum64()%uint64(2*jitterSeconds+1)) - jitterSeconds
+	return t.base + time.Duration(offset)*time.Second
+}
+
+// ttlFromAnnotations parses the ttl annotation, it reports whether the annotation is set.
+func ttlFromAnnotations(annotations map[string]string, uid types.UID) (time.Duration, bool, error) {
+	ttlString := annotations[TTLAnnotation]
+	if ttlString == "" {
+		return time.Duration(0), false, nil
+	}
+	spec, err := parseTTL(ttlString)
+	if err != nil {
+		return time.Duration(0), true, err
+	}
+	return spec.forPod(uid), true, nil
+}
+
+// expiresAtFromAnnotations parses the expiry date annotation, it reports whether the annotation is set.
+func expiresAtFromAnnotations(annotations map[string]string) (time.Time, bool, error) {
+	expiresAtString := annotations[ExpiresAtAnnotation]
+	if expiresAtString == "" {
+		return time.Time{}, false, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiresAtString)
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	return expiresAt, true, nil
+}
+
+// ageSourceFromAnnotations reads the age source annotation, it reports whether the annotation is set.
+func ageSourceFromAnnotations(annotations map[string]string) (string, bool, error) {
+	ageSource := annotations[AgeSourceAnnotation]
+	if ageSource == "" {
+		return "", false, nil
+	}
+	if !ValidAgeSource(ageSource) {
+		return "", true, fmt.Errorf("unknown age source %q", ageSource)
+	}
+	return ageSource, true, nil
+}
+
+// maxRestartsFromAnnotations parses the max restarts annotation, it reports whether the annotation is set.
+func maxRestartsFromAnnotations(annotations map[string]string) (int, bool, error) {
+	value := annotations[MaxRestartsAnnotation]
+	if value == "" {
+		return 0, false, nil
+	}
+	maxRestarts, err := strconv.Atoi(value)
+	if err != nil || maxRestarts < 0 {
+		return 0, true, fmt.Errorf("invalid max restarts %q, it must be a positive number", value)
+	}
+	return maxRestarts, true, nil
+}
+
+// oomKillWindowFromAnnotations parses the OOM kill window annotation, it reports whether the annotation is set.
+func oomKillWindowFromAnnotations(annotations map[string]string) (time.Duration, bool, error) {
+	value := annotations[OOMKillWindowAnnotation]
+	if value == "" {
+		return time.Duration(0), false, nil
+	}
+	window, err := parseDuration(value)
+	if err != nil {
+		return time.Duration(0), true, err
+	}
+	if window < 0 {
+		return time.Duration(0), true, fmt.Errorf("invalid OOM kill window %q, it must be positive", value)
+	}
+	return window, true, nil
+}
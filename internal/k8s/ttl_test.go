@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseTTL(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		value        string
+		expectedSpec ttlSpec
+		expectErr    bool
+	}
+
+	data := map[string]unitData{
+		"go duration":            {value: "1h30m", expectedSpec: ttlSpec{base: 90 * time.Minute}},
+		"days":                   {value: "7d", expectedSpec: ttlSpec{base: 7 * day}},
+		"weeks and days":         {value: "1w2d", expectedSpec: ttlSpec{base: 9 * day}},
+		"days and hours":         {value: "1.5d12h", expectedSpec: ttlSpec{base: 2 * day}},
+		"jitter":                 {value: "24h ± 2h", expectedSpec: ttlSpec{base: 24 * time.Hour, jitter: 2 * time.Hour}},
+		"ascii jitter":           {value: "7d+-1d", expectedSpec: ttlSpec{base: 7 * day, jitter: day}},
+		"spaces between units":   {value: " 1d 2h ", expectedSpec: ttlSpec{base: 26 * time.Hour}},
+		"zero":                   {value: "0s", expectErr: true},
+		"negative":               {value: "-1h", expectErr: true},
+		"negative days":          {value: "-1d2h", expectErr: true},
+		"mixed signs":            {value: "1d-2h", expectErr: true},
+		"wrong unit":             {value: "7y", expectErr: true},
+		"wrong jitter":           {value: "24h±wrong", expectErr: true},
+		"jitter bigger than ttl": {value: "1h±2h", expectErr: true},
+		"jitter equal to ttl":    {value: "1h±1h", expectErr: true},
+		"empty":                  {value: "", expectErr: true},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				spec, err := parseTTL(unit.value)
+
+				if unit.expectErr {
+					assert.NotNil(t, err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, unit.expectedSpec, spec)
+			}
+		}(unit))
+	}
+}
+
+func TestTTLJitter(t *testing.T) {
+	t.Parallel()
+
+	spec := ttlSpec{base: 24 * time.Hour, jitter: 2 * time.Hour}
+	ttls := make(map[time.Duration]bool)
+	for _, uid := range []types.UID{"uid-1", "uid-2", "uid-3", "uid-4"} {
+		ttl := spec.forPod(uid)
+		assert.Equal(t, ttl, spec.forPod(uid), "jitter must be stable for a given uid")
+		assert.GreaterOrEqual(t, ttl, 22*time.Hour)
+		assert.LessOrEqual(t, ttl, 26*time.Hour)
+		ttls[ttl] = true
+	}
+	assert.Greater(t, len(ttls), 1, "pods must not share the same ttl")
+}
+
+func TestExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC)
+	podCreatedAt := func(created time.Time) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	}
+
+	assert.True(t, Expiration{TTL: time.Hour}.Expired(podCreatedAt(now), 2*time.Hour, now))
+	assert.False(t, Expiration{TTL: time.Hour}.Expired(podCreatedAt(now), 30*time.Minute, now))
+	// created before the expiry date
+	assert.True(t, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Expired(podCreatedAt(expiresAt.Add(-time.Hour)), 2*time.Hour, now))
+	// created after the expiry date
+	assert.False(t, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Expired(podCreatedAt(expiresAt.Add(time.Minute)), 59*time.Minute, now))
+	// expiry date not reached yet
+	assert.False(t, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Expired(podCreatedAt(expiresAt.Add(-time.Hour)), 30*time.Minute, expiresAt.Add(-30*time.Minute)))
+}
+
+func TestOverdue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC)
+	podCreatedAt := func(created time.Time) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	}
+
+	assert.Equal(t, time.Hour, Expiration{TTL: time.Hour}.Overdue(podCreatedAt(now), 2*time.Hour, now))
+	assert.Equal(t, time.Duration(0), Expiration{TTL: time.Hour}.Overdue(podCreatedAt(now), 30*time.Minute, now))
+	// the expiry date is reached before the ttl
+	assert.Equal(t, time.Hour, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Overdue(podCreatedAt(expiresAt.Add(-time.Hour)), 2*time.Hour, now))
+	// created after the expiry date
+	assert.Equal(t, time.Duration(0), Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Overdue(podCreatedAt(expiresAt.Add(time.Minute)), 59*time.Minute, now))
+}
+
+func TestRemaining(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC)
+	podCreatedAt := func(created time.Time) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	}
+
+	assert.Equal(t, 30*time.Minute, Expiration{TTL: time.Hour}.Remaining(podCreatedAt(now), 30*time.Minute, now))
+	assert.Equal(t, time.Duration(0), Expiration{TTL: time.Hour}.Remaining(podCreatedAt(now), 2*time.Hour, now))
+	// the expiry date is reached before the ttl
+	assert.Equal(t, time.Hour, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Remaining(podCreatedAt(now.Add(-time.Hour)), time.Hour, now))
+	// created after the expiry date
+	assert.Equal(t, 23*time.Hour, Expiration{TTL: day, ExpiresAt: expiresAt}.
+		Remaining(podCreatedAt(expiresAt.Add(time.Minute)), time.Hour, now))
+}
+
+func TestExpirationAge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC)
+	startTime := metav1.NewTime(now.Add(-time.Hour))
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		Status:     v1.PodStatus{StartTime: &startTime},
+	}
+
+	assert.Equal(t, 2*time.Hour, Expiration{}.Age(pod, AgeSourceCreation, now))
+	// the annotated age source prevails over the default one
+	assert.Equal(t, time.Hour, Expiration{AgeSource: AgeSourceStartTime}.Age(pod, AgeSourceCreation, now))
+}
@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+	KindReplicaSet  = "ReplicaSet"
+
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+	// reasons for which a workload isn't stable
+	UnstableRollout  = "rollout-in-progress"
+	UnstableHPAAtMax = "hpa-at-max"
+)
+
+// Owner identifies the workload controlling a pod.
+type Owner struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String returns a unique key for the owner, e.g. Deployment/default/nginx.
+func (o Owner) String() string {
+	return fmt.Sprintf("%s/%s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// OwnerFromPod returns the workload (Deployment, StatefulSet or DaemonSet) controlling the pod.
+// It returns nil when the pod isn't controlled by one of those workloads.
+func (k KubernetesClient) OwnerFromPod(ctx context.Context, pod v1.Pod) (*Owner, error) {
+	chain, err := k.ownerChain(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range chain {
+		switch owner.Kind {
+		case KindDeployment, KindStatefulSet, KindDaemonSet:
+			return &Owner{Kind: owner.Kind, Namespace: owner.Namespace, Name: owner.Name}, nil
+		}
+	}
+	return nil, nil
+}
+
+// RestartWorkload triggers a rolling restart of the owner, the same way `kubectl rollout restart` does.
+// The restartedAt annotation is patched on the pod template, so kubernetes honors the workload's update strategy.
+func (k KubernetesClient) RestartWorkload(ctx context.Context, owner Owner) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+
+	var err error
+	switch owner.Kind {
+	case KindDeployment:
+		_, err = k.clientSet.AppsV1().Deployments(owner.Namespace).Patch(ctx, owner.Name,
+			types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case KindStatefulSet:
+		_, err = k.clientSet.AppsV1().StatefulSets(owner.Namespace).Patch(ctx, owner.Name,
+			types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case KindDaemonSet:
+		_, err = k.clientSet.AppsV1().DaemonSets(owner.Namespace).Patch(ctx, owner.Name,
+			types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	default:
+		return fmt.Errorf("k8s: unsupported owner kind for rollout restart, %v", owner.Kind)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to restart workload")
+	}
+	return nil
+}
+
+// WorkloadReplicas returns the desired and ready replicas of the owner.
+func (k KubernetesClient) WorkloadReplicas(ctx context.Context, owner Owner) (desired, ready int32, err error) {
+	getOpts := metav1.GetOptions{}
+	switch owner.Kind {
+	case KindDeployment:
+		deployment, err := k.clientSet.AppsV1().Deployments(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "failed to get deployment")
+		}
+		return replicasOrDefault(deployment.Spec.Replicas), deployment.Status.ReadyReplicas, nil
+	case KindStatefulSet:
+		statefulSet, err := k.clientSet.AppsV1().StatefulSets(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "failed to get statefulset")
+		}
+		return replicasOrDefault(statefulSet.Spec.Replicas), statefulSet.Status.ReadyReplicas, nil
+	case KindDaemonSet:
+		daemonSet, err := k.clientSet.AppsV1().DaemonSets(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "failed to get daemonset")
+		}
+		return daemonSet.Status.DesiredNumberScheduled, daemonSet.Status.NumberReady, nil
+	default:
+		return 0, 0, fmt.Errorf("k8s: unsupported owner kind for replicas, %v", owner.Kind)
+	}
+}
+
+// replicasOrDefault returns the replicas of a spec, kubernetes defaults them to 1.
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// WorkloadUnstable returns why the owner isn't stable: its rollout is in progress,
+// or an autoscaler targeting it is at its max replicas. It returns an empty reason for a stable owner.
+func (k KubernetesClient) WorkloadUnstable(ctx context.Context, owner Owner) (string, error) {
+	rollingOut, err := k.rollingOut(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	if rollingOut {
+		return UnstableRollout, nil
+	}
+
+	hpa, err := k.ownerHPA(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	if hpa != nil && hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas {
+		return UnstableHPAAtMax, nil
+	}
+	return "", nil
+}
+
+// rollingOut reports whether the owner's rollout is in progress, the same way `kubectl rollout status` does.
+func (k KubernetesClient) rollingOut(ctx context.Context, owner Owner) (bool, error) {
+	getOpts := metav1.GetOptions{}
+	switch owner.Kind {
+	case KindDeployment:
+		deployment, err := k.clientSet.AppsV1().Deployments(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get deployment")
+		}
+		return deploymentRollingOut(deployment), nil
+	case KindStatefulSet:
+		statefulSet, err := k.clientSet.AppsV1().StatefulSets(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get statefulset")
+		}
+		return statefulSetRollingOut(statefulSet), nil
+	case KindDaemonSet:
+		daemonSet, err := k.clientSet.AppsV1().DaemonSets(owner.Namespace).Get(ctx, owner.Name, getOpts)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get daemonset")
+		}
+		return daemonSetRollingOut(daemonSet), nil
+	default:
+		return false, fmt.Errorf("k8s: unsupported owner kind for rollout status, %v", owner.Kind)
+	}
+}
+
+func deploymentRollingOut(deployment *appsv1.Deployment) bool {
+	status := deployment.Status
+	return deployment.Generation > status.ObservedGeneration ||
+		status.UpdatedReplicas < replicasOrDefault(deployment.Spec.Replicas) ||
+		status.Replicas > status.UpdatedReplicas ||
+		status.AvailableReplicas < status.UpdatedReplicas
+}
+
+func statefulSetRollingOut(statefulSet *appsv1.StatefulSet) bool {
+	status := statefulSet.Status
+	return statefulSet.Generation > status.ObservedGeneration ||
+		status.UpdatedReplicas < replicasOrDefault(statefulSet.Spec.Replicas) ||
+		status.CurrentRevision != status.UpdateRevision
+}
+
+func daemonSetRollingOut(daemonSet *appsv1.DaemonSet) bool {
+	status := daemonSet.Status
+	return daemonSet.Generation > status.ObservedGeneration ||
+		status.UpdatedNumberScheduled < status.DesiredNumberScheduled ||
+		status.NumberAvailable < status.DesiredNumberScheduled
+}
@@ -0,0 +1,178 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func controllerRef(kind, name string) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &isController}}
+}
+
+func TestOwnerFromPod(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod           v1.Pod
+		expectedOwner *Owner
+	}
+
+	clientSet := testclient.NewSimpleClientset(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "app-1-5d8f",
+			Namespace:       "ns1",
+			OwnerReferences: controllerRef(KindDeployment, "app-1"),
+		},
+	}, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-1",
+			Namespace: "ns1",
+		},
+	}, &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db",
+			Namespace: "ns1",
+		},
+	}, &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "orphan-rs",
+			Namespace: "ns1",
+		},
+	})
+
+	data := map[string]unitData{
+		"deployment through replicaset": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				OwnerReferences: controllerRef(KindReplicaSet, "app-1-5d8f"),
+			}},
+			expectedOwner: &Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"},
+		},
+		"statefulset": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				OwnerReferences: controllerRef(KindStatefulSet, "db"),
+			}},
+			expectedOwner: &Owner{Kind: KindStatefulSet, Namespace: "ns1", Name: "db"},
+		},
+		"replicaset without deployment": {
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "ns1",
+				OwnerReferences: controllerRef(KindReplicaSet, "orphan-rs"),
+			}},
+			expectedOwner: nil,
+		},
+		"bare pod": {
+			pod:           v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1"}},
+			expectedOwner: nil,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				k8sClient := InitKubernetesClient(clientSet)
+
+				owner, err := k8sClient.OwnerFromPod(context.Background(), unit.pod)
+
+				assert.Nil(t, err)
+				assert.Equal(t, unit.expectedOwner, owner)
+			}
+		}(unit))
+	}
+}
+
+func TestRestartWorkload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clientSet := testclient.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "ns1"},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+
+	err := k8sClient.RestartWorkload(ctx, Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"})
+	assert.Nil(t, err)
+
+	deployment, err := clientSet.AppsV1().Deployments("ns1").Get(ctx, "app-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, deployment.Spec.Template.Annotations[restartedAtAnnotation])
+
+	err = k8sClient.RestartWorkload(ctx, Owner{Kind: "Job", Namespace: "ns1", Name: "job-1"})
+	assert.NotNil(t, err)
+}
+
+func TestWorkloadReplicas(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	replicas := int32(3)
+	clientSet := testclient.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "ns1"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 2},
+	}, &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns1"},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+	})
+	k8sClient := InitKubernetesClient(clientSet)
+
+	desired, ready, err := k8sClient.WorkloadReplicas(ctx, Owner{Kind: KindDeployment, Namespace: "ns1", Name: "app-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), desired)
+	assert.Equal(t, int32(2), ready)
+
+	desired, ready, err = k8sClient.WorkloadReplicas(ctx, Owner{Kind: KindStatefulSet, Namespace: "ns1", Name: "db"})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), desired)
+	assert.Equal(t, int32(1), ready)
+
+	_, _, err = k8sClient.WorkloadReplicas(ctx, Owner{Kind: KindDeployment, Namespace: "ns1", Name: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestWorkloadUnstable(t *testing.T) {
+	t.Parallel()
+
+	replicas := int32(3)
+	stable := appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}
+	deployment := func(name string, status appsv1.DeploymentStatus) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     status,
+		}
+	}
+	clientSet := testclient.NewSimpleClientset(
+		deployment("stable", stable),
+		deployment("rolling-out", appsv1.DeploymentStatus{Replicas: 4, UpdatedReplicas: 1, AvailableReplicas: 3}),
+		deployment("autoscaled", stable),
+		&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "autoscaled", Namespace: "ns1"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: KindDeployment, Name: "autoscaled"},
+				MaxReplicas:    3,
+			},
+			Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 3},
+		},
+	)
+	k8sClient := InitKubernetesClient(clientSet)
+
+	for name, expected := range map[string]string{
+		"stable":      "",
+		"rolling-out": UnstableRollout,
+		"autoscaled":  UnstableHPAAtMax,
+	} {
+		reason, err := k8sClient.WorkloadUnstable(context.Background(), Owner{Kind: KindDeployment, Namespace: "ns1", Name: name})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, reason, name)
+	}
+}
@@ -0,0 +1,256 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	skipReasonCircuitOpen  = "circuit-open"
+	eventReasonCircuitOpen = "RaccoonCircuitOpen"
+)
+
+var (
+	replacementFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_replacement_failures_total",
+			Help: "The total number of evicted pods whose replacement didn't become ready in time",
+		},
+		[]string{"namespace", "owner"})
+	circuitOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raccoon_circuit_open",
+			Help: "Whether collection is stopped because of replacement failures, owner is empty for a namespace circuit",
+		},
+		[]string{"namespace", "owner"})
+)
+
+// replacement is an evicted pod whose owner is expected to be fully ready again before the deadline.
+type replacement struct {
+	owner    k8s.Owner
+	deadline time.Time
+}
+
+type circuit struct {
+	failures int
+	// owners which failed, a namespace circuit needs failures from several owners
+	owners   map[string]bool
+	openedAt time.Time
+}
+
+func (c *circuit) isOpen() bool {
+	return !c.openedAt.IsZero()
+}
+
+// breaker stops collection in an owner, or a namespace, when replacements of evicted pods
+// repeatedly fail to become ready. An open circuit closes after the cooldown, or on a manual reset.
+type breaker struct {
+	mu          sync.Mutex
+	maxFailures int
+	deadline    time.Duration
+	cooldown    time.Duration
+	pending     []replacement
+	owners      map[string]*circuit
+	namespaces  map[string]*circuit
+}
+
+func newBreaker(maxFailures int, deadline, cooldown time.Duration) *breaker {
+	return &breaker{
+		maxFailures: maxFailures,
+		deadline:    deadline,
+		cooldown:    cooldown,
+		owners:      make(map[string]*circuit),
+		namespaces:  make(map[string]*circuit),
+	}
+}
+
+func (b *breaker) enabled() bool {
+	return b.maxFailures > 0
+}
+
+// recordEviction starts tracking the replacement of an evicted pod.
+func (b *breaker) recordEviction(owner k8s.Owner, now time.Time) {
+	if !b.enabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, replacement{owner: owner, deadline: now.Add(b.deadline)})
+}
+
+// due returns, and stops tracking, the replacements whose deadline is passed.
+func (b *breaker) due(now time.Time) []replacement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var due, pending []replacement
+	for _, r := range b.pending {
+		if now.Before(r.deadline) {
+			pending = append(pending, r)
+		} else {
+			due = append(due, r)
+		}
+	}
+	b.pending = pending
+	return due
+}
+
+// recordSuccess resets the consecutive failures of the owner, and takes one failure off its namespace's count.
+// A namespace whose count is back to zero forgets the owners which failed.
+func (b *breaker) recordSuccess(owner k8s.Owner) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.owners[owner.String()]; ok && !c.isOpen() {
+		c.failures = 0
+	}
+	if c, ok := b.namespaces[owner.Namespace]; ok && !c.isOpen() && c.failures > 0 {
+		c.failures--
+		if c.failures == 0 {
+			c.owners = make(map[string]bool)
+		}
+	}
+}
+
+// recordFailure counts a replacement failure, it reports which circuits have just been opened.
+func (b *breaker) recordFailure(owner k8s.Owner, now time.Time) (ownerOpened, namespaceOpened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ownerCircuit := circuitOf(b.owners, owner.String())
+	ownerCircuit.failures++
+	if !ownerCircuit.isOpen() && ownerCircuit.failures >= b.maxFailures {
+		ownerCircuit.openedAt = now
+		ownerOpened = true
+	}
+
+	nsCircuit := circuitOf(b.namespaces, owner.Namespace)
+	nsCircuit.failures++
+	nsCircuit.owners[owner.String()] = true
+	if !nsCircuit.isOpen() && nsCircuit.failures >= b.maxFailures && len(nsCircuit.owners) > 1 {
+		nsCircuit.openedAt = now
+		namespaceOpened = true
+	}
+	return ownerOpened, namespaceOpened
+}
+
+// isOpen reports whether the marked pod's namespace or owner circuit is open.
+// Circuits whose cooldown is over are closed.
+func (b *breaker) isOpen(nsPod *namespacedPod, now time.Time) bool {
+	if !b.enabled() {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closeAfterCooldown(b.namespaces, nsPod.namespace, nsPod.namespace, "", now) {
+		return true
+	}
+	if nsPod.owner == nil {
+		return false
+	}
+	return b.closeAfterCooldown(b.owners, nsPod.owner.String(), nsPod.namespace, nsPod.owner.String(), now)
+}
+
+// closeAfterCooldown reports whether the circuit is still open, it closes it once its cooldown is over.
+func (b *breaker) closeAfterCooldown(circuits map[string]*circuit, key, namespace, owner string,
+	now time.Time) bool {
+	c, ok := circuits[key]
+	if !ok || !c.isOpen() {
+		return false
+	}
+	if now.Sub(c.openedAt) < b.cooldown {
+		return true
+	}
+	delete(circuits, key)
+	circuitOpen.With(prometheus.Labels{"namespace": namespace, "owner": owner}).Set(0)
+	log.WithFields(logrus.Fields{"namespace": namespace, "owner": owner}).Info("circuit cooldown over, closing circuit")
+	return false
+}
+
+// reset closes the circuits of a namespace, or of an owner when set.
+func (b *breaker) reset(namespace, owner string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if owner != "" {
+		delete(b.owners, owner)
+	} else {
+		delete(b.namespaces, namespace)
+	}
+	circuitOpen.With(prometheus.Labels{"namespace": namespace, "owner": owner}).Set(0)
+	log.WithFields(logrus.Fields{"namespace": namespace, "owner": owner}).Info("circuit manually reset")
+}
+
+func circuitOf(circuits map[string]*circuit, key string) *circuit {
+	c, ok := circuits[key]
+	if !ok {
+		c = &circuit{owners: make(map[string]bool)}
+		circuits[key] = c
+	}
+	return c
+}
+
+// checkReplacements verifies the replacements whose deadline is passed: the owner must be fully ready again.
+// Circuits are opened when too many of them fail.
+func (d *RandomizedDelay) checkReplacements(ctx context.Context) {
+	now := time.Now()
+	for _, r := range d.breaker.due(now) {
+		lFields := logrus.Fields{"namespace": r.owner.Namespace, "owner": r.owner.String()}
+		desired, ready, err := d.k8sClient.WorkloadReplicas(ctx, r.owner)
+		if err != nil {
+			log.WithFields(lFields).Errorf("error while checking pod's replacement: %v", err)
+			continue
+		}
+		if ready >= desired {
+			d.breaker.recordSuccess(r.owner)
+			continue
+		}
+
+		log.WithFields(lFields).WithFields(logrus.Fields{"desired": desired, "ready": ready}).
+			Warn("evicted pod's replacement isn't ready before the deadline")
+		replacementFailures.With(prometheus.Labels{"namespace": r.owner.Namespace, "owner": r.owner.String()}).Inc()
+		ownerOpened, namespaceOpened := d.breaker.recordFailure(r.owner, now)
+		if ownerOpened {
+			d.openCircuit(ctx, r.owner, r.owner.Namespace, r.owner.String())
+		}
+		if namespaceOpened {
+			d.openCircuit(ctx, k8s.Owner{Kind: k8s.KindNamespace, Name: r.owner.Namespace}, r.owner.Namespace, "")
+		}
+	}
+}
+
+func (d *RandomizedDelay) openCircuit(ctx context.Context, target k8s.Owner, namespace, owner string) {
+	lFields := logrus.Fields{"namespace": namespace, "owner": owner}
+	log.WithFields(lFields).Error("too many replacement failures, opening circuit")
+	circuitOpen.With(prometheus.Labels{"namespace": namespace, "owner": owner}).Set(1)
+
+	message := fmt.Sprintf("raccoon stopped collecting pods after %d replacement failures, "+
+		"it resumes after %v or on a manual reset", d.breaker.maxFailures, d.breaker.cooldown)
+	err := d.k8sClient.EmitEvent(ctx, target.Reference(), k8s.EventTypeWarning, eventReasonCircuitOpen, message)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+	}
+}
+
+// ResetHandler closes circuits on demand, e.g. `POST /circuits/reset?namespace=default&owner=Deployment/default/app`.
+// Without owner, the namespace circuit is reset. It must only be served on the admin listener, not on the metrics one.
+func (d *RandomizedDelay) ResetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		namespace := r.URL.Query().Get("namespace")
+		if namespace == "" {
+			http.Error(w, "namespace is required", http.StatusBadRequest)
+			return
+		}
+		d.breaker.reset(namespace, r.URL.Query().Get("owner"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
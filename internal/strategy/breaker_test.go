@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	app1 := k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	app2 := k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-2"}
+	k8sMock.On("WorkloadReplicas", ctx, app1).Return(int32(3), int32(2), nil)
+	k8sMock.On("WorkloadReplicas", ctx, app2).Return(int32(3), int32(2), nil)
+	k8sMock.On("EmitEvent", ctx, app1.Reference(), k8s.EventTypeWarning, eventReasonCircuitOpen, mock.Anything).
+		Return(nil).Once()
+	k8sMock.On("EmitEvent", ctx, k8s.Owner{Kind: k8s.KindNamespace, Name: "namespace-1"}.Reference(),
+		k8s.EventTypeWarning, eventReasonCircuitOpen, mock.Anything).Return(nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		BreakerFailures: 2,
+		BreakerDeadline: 0,
+		BreakerCooldown: time.Hour,
+	}, k8sMock)
+	now := time.Now()
+
+	// first failure, the circuit stays closed
+	d.breaker.recordEviction(app1, now.Add(-time.Minute))
+	d.checkReplacements(ctx)
+	assert.False(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1", owner: &app1}, now))
+
+	// second failure opens the owner's circuit only
+	d.breaker.recordEviction(app1, now.Add(-time.Minute))
+	d.checkReplacements(ctx)
+	assert.True(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1", owner: &app1}, now))
+	assert.False(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1", owner: &app2}, now))
+
+	// another owner failing opens the namespace's circuit
+	d.breaker.recordEviction(app2, now.Add(-time.Minute))
+	d.checkReplacements(ctx)
+	assert.True(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1"}, now))
+
+	// manual reset of the namespace
+	recorder := httptest.NewRecorder()
+	d.ResetHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/circuits/reset?namespace=namespace-1", nil))
+	assert.Equal(http.StatusNoContent, recorder.Code)
+	assert.False(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1"}, now))
+
+	// cooldown closes the owner's circuit
+	assert.False(d.breaker.isOpen(&namespacedPod{namespace: "namespace-1", owner: &app1}, now.Add(2*time.Hour)))
+	k8sMock.AssertExpectations(t)
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	app1 := k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	k8sMock.On("WorkloadReplicas", ctx, app1).Return(int32(3), int32(2), nil).Once()
+	k8sMock.On("WorkloadReplicas", ctx, app1).Return(int32(3), int32(3), nil).Once()
+	k8sMock.On("WorkloadReplicas", ctx, app1).Return(int32(3), int32(2), nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{BreakerFailures: 2, BreakerCooldown: time.Hour}, k8sMock)
+	for i := 0; i < 3; i++ {
+		d.breaker.recordEviction(app1, time.Now().Add(-time.Minute))
+		d.checkReplacements(ctx)
+	}
+
+	assert.False(t, d.breaker.isOpen(&namespacedPod{namespace: "namespace-1", owner: &app1}, time.Now()))
+	k8sMock.AssertExpectations(t)
+}
+
+func TestBreakerSuccessDecaysNamespaceFailures(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	app1 := k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	app2 := k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-2"}
+	k8sMock.On("WorkloadReplicas", ctx, app1).Return(int32(3), int32(2), nil).Once()
+	k8sMock.On("WorkloadReplicas", ctx, app2).Return(int32(3), int32(3), nil).Once()
+	k8sMock.On("WorkloadReplicas", ctx, app2).Return(int32(3), int32(2), nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{BreakerFailures: 2, BreakerCooldown: time.Hour}, k8sMock)
+	for _, owner := range []k8s.Owner{app1, app2, app2} {
+		d.breaker.recordEviction(owner, time.Now().Add(-time.Minute))
+		d.checkReplacements(ctx)
+	}
+
+	// the success in between takes app-1's failure off the namespace's count
+	assert.False(t, d.breaker.isOpen(&namespacedPod{namespace: "namespace-1"}, time.Now()))
+	k8sMock.AssertExpectations(t)
+}
@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"context"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// checkedPod is a pod to collect going through the skip checks.
+type checkedPod struct {
+	pod     v1.Pod
+	nsPod   *namespacedPod
+	cycle   *markingCycle
+	lFields logrus.Fields
+}
+
+// skipCheck returns the reason why a pod to collect is skipped, empty when the pod passes the check.
+// A check failing on an error leaves the pod to the next run, without counting it as skipped.
+type skipCheck func(d *RandomizedDelay, ctx context.Context, p checkedPod) (string, error)
+
+// skipChecks are run in order on the pods to collect, the owner is resolved before the checks needing it.
+var skipChecks = []skipCheck{
+	(*RandomizedDelay).checkOptedOut,
+	(*RandomizedDelay).checkExcluded,
+	(*RandomizedDelay).checkPreEvictHook,
+	(*RandomizedDelay).resolveOwner,
+	(*RandomizedDelay).checkPaused,
+	(*RandomizedDelay).checkHealthGate,
+	(*RandomizedDelay).checkBreaker,
+	(*RandomizedDelay).checkBackoff,
+	(*RandomizedDelay).checkStability,
+}
+
+// skipReason runs the skip checks on the pod, it stops at the first one skipping it or failing.
+func (d *RandomizedDelay) skipReason(ctx context.Context, p checkedPod) (string, error) {
+	for _, check := range skipChecks {
+		if reason, err := check(d, ctx, p); reason != "" || err != nil {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+func (d *RandomizedDelay) checkOptedOut(_ context.Context, p checkedPod) (string, error) {
+	_, reason := k8s.OptedOut(p.pod, time.Now())
+	return reason, nil
+}
+
+func (d *RandomizedDelay) checkExcluded(_ context.Context, p checkedPod) (string, error) {
+	return d.excluded(p.pod), nil
+}
+
+func (d *RandomizedDelay) checkPreEvictHook(_ context.Context, p checkedPod) (string, error) {
+	hook, err := k8s.PreEvictHookURL(p.pod)
+	if err != nil {
+		p.lFields["error"] = err.Error()
+		return skipReasonInvalidHook, nil
+	}
+	p.nsPod.preEvictHook = hook
+	return "", nil
+}
+
+func (d *RandomizedDelay) resolveOwner(ctx context.Context, p checkedPod) (string, error) {
+	owner, err := d.k8sClient.OwnerFromPod(ctx, p.pod)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve pod's owner")
+	}
+	p.nsPod.owner = owner
+	return "", nil
+}
+
+func (d *RandomizedDelay) checkPaused(_ context.Context, p checkedPod) (string, error) {
+	paused, reason := d.k8sClient.Paused(p.nsPod.namespace)
+	if !paused {
+		return "", nil
+	}
+	p.lFields["pauseReason"] = reason
+	return skipReasonPaused, nil
+}
+
+func (d *RandomizedDelay) checkHealthGate(_ context.Context, p checkedPod) (string, error) {
+	_, reason := p.cycle.gate.suspended(p.nsPod)
+	return reason, nil
+}
+
+func (d *RandomizedDelay) checkBreaker(_ context.Context, p checkedPod) (string, error) {
+	if d.breaker.isOpen(p.nsPod, time.Now()) {
+		return skipReasonCircuitOpen, nil
+	}
+	return "", nil
+}
+
+func (d *RandomizedDelay) checkBackoff(_ context.Context, p checkedPod) (string, error) {
+	if d.retries.waiting(p.nsPod.uid, time.Now()) {
+		return skipReasonEvictionBackoff, nil
+	}
+	return "", nil
+}
+
+// checkStability defers the pods of unstable owners. Stale pods aren't deferred until their owner's rollout ends,
+// they are left behind by rollouts which don't progress, e.g. of a paused Deployment.
+func (d *RandomizedDelay) checkStability(ctx context.Context, p checkedPod) (string, error) {
+	reason, err := d.unstableOwner(ctx, p.nsPod, p.cycle)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to check owner's stability")
+	}
+	if reason == k8s.UnstableRollout && p.nsPod.criterion == criterionStaleTemplate {
+		return "", nil
+	}
+	if reason != "" {
+		p.lFields["owner"] = p.nsPod.owner.String()
+	}
+	return reason, nil
+}
@@ -0,0 +1,36 @@
+package strategy
+
+import (
+	"sync"
+	"time"
+)
+
+// cooldown remembers when an action was last taken on a key,
+// to avoid taking it again before the cooldown period is over.
+type cooldown struct {
+	mu     sync.Mutex
+	period time.Duration
+	last   map[string]time.Time
+}
+
+func newCooldown(period time.Duration) *cooldown {
+	return &cooldown{
+		period: period,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// active reports whether the key is still in its cooldown period.
+func (c *cooldown) active(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.last[key]
+	return ok && now.Sub(last) < c.period
+}
+
+// record starts a new cooldown period for the key.
+func (c *cooldown) record(key string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last[key] = now
+}
@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// criteria for which pods are collected
+	criterionTTL           = "ttl"
+	criterionRestarts      = "restarts"
+	criterionOOMKilled     = "oom-killed"
+	criterionMemory        = "memory"
+	criterionStaleTemplate = "stale-template"
+	criterionConfigChanged = "config-changed"
+
+	eventReasonCollected = "RaccoonPodCollected"
+
+	oomKilledReason = "OOMKilled"
+)
+
+// criteriaDescriptions tells why pods are collected for the criteria other than the ttl.
+var criteriaDescriptions = map[string]string{
+	criterionRestarts:      "its containers restarted too many times",
+	criterionOOMKilled:     "one of its containers has been OOM killed recently",
+	criterionMemory:        "one of its containers has been using most of its memory limit for too long",
+	criterionStaleTemplate: "it differs from its owner's current template",
+	criterionConfigChanged: "a ConfigMap or a Secret it references changed after it started",
+}
+
+// criterion returns the criterion the pod must be collected for, empty when it mustn't be collected.
+// The ttl prevails over the other criteria, which are checked from the cheapest to the most expensive.
+// A criterion which can't be evaluated doesn't collect the pod.
+func (d *RandomizedDelay) criterion(ctx context.Context, pod v1.Pod, expiration k8s.Expiration, age time.Duration,
+	lFields logrus.Fields) string {
+	if expiration.Expired(pod, age, time.Now()) {
+		return criterionTTL
+	}
+	if criterion := d.statusCriterion(pod, expiration, lFields); criterion != "" {
+		return criterion
+	}
+	return d.apiCriterion(ctx, pod, lFields)
+}
+
+// statusCriterion returns the criterion the pod must be collected for, among the ones read from its status
+// and from the memory usage tracked by raccoon. The max restarts and the OOM kill window annotated prevail.
+func (d *RandomizedDelay) statusCriterion(pod v1.Pod, expiration k8s.Expiration, lFields logrus.Fields) string {
+	maxRestarts := expiration.MaxRestartsOrDefault(d.defaultSettings.MaxRestarts)
+	if maxRestarts > 0 && podRestarts(pod) >= int32(maxRestarts) {
+		lFields["restarts"] = podRestarts(pod)
+		return criterionRestarts
+	}
+	window := expiration.OOMKillWindowOrDefault(d.defaultSettings.OOMKillWindow)
+	if window > 0 && oomKilledWithin(pod, window, time.Now()) {
+		return criterionOOMKilled
+	}
+	if d.defaultSettings.MemoryLimitRatio > 0 &&
+		d.memory.sustained(pod.ObjectMeta.UID, d.defaultSettings.MemorySustainPeriod, time.Now()) {
+		return criterionMemory
+	}
+	return ""
+}
+
+// apiCriterion returns the criterion the pod must be collected for, among the ones comparing it with other objects
+// read from the api.
+func (d *RandomizedDelay) apiCriterion(ctx context.Context, pod v1.Pod, lFields logrus.Fields) string {
+	if d.defaultSettings.CollectStaleTemplates {
+		stale, err := d.k8sClient.StaleTemplate(ctx, pod)
+		if err != nil {
+			log.WithFields(lFields).Errorf("error while comparing pod with its owner's template: %v", err)
+		} else if stale {
+			return criterionStaleTemplate
+		}
+	}
+	if d.defaultSettings.CollectConfigChanges {
+		config, err := d.k8sClient.ChangedConfig(ctx, pod)
+		if err != nil {
+			log.WithFields(lFields).Errorf("error while checking pod's configs: %v", err)
+		} else if config != nil {
+			lFields["config"] = config.String()
+			return criterionConfigChanged
+		}
+	}
+	return ""
+}
+
+// oomKilledWithin reports whether a container of the pod has been OOM killed during the window,
+// looking at its current and last terminations.
+func oomKilledWithin(pod v1.Pod, window time.Duration, now time.Time) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{
+			status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == oomKilledReason &&
+				now.Sub(terminated.FinishedAt.Time) <= window {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emitCollected tells, in an event on the pod, why a pod collected for another criterion than its ttl has been.
+func (d *RandomizedDelay) emitCollected(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	description, ok := criteriaDescriptions[markedPod.criterion]
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("pod collected as %s (%s)", description, markedPod.criterion)
+	err := d.k8sClient.EmitEvent(ctx, k8s.PodReference(markedPod.namespace, markedPod.name),
+		k8s.EventTypeNormal, eventReasonCollected, message)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+	}
+}
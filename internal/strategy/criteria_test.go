@@ -0,0 +1,197 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCriterion(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		age           time.Duration
+		collectStale  bool
+		stale         bool
+		staleErr      error
+		collectConfig bool
+		config        *k8s.Owner
+		statuses      []v1.ContainerStatus
+		maxRestarts   *int
+		oomKillWindow *time.Duration
+		expected      string
+	}
+
+	noRestarts, fewRestarts := 0, 3
+	threeHours := 3 * time.Hour
+
+	oomKilled := func(finishedAt time.Time) v1.ContainerState {
+		return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			Reason:     oomKilledReason,
+			FinishedAt: metav1.NewTime(finishedAt),
+		}}
+	}
+
+	data := map[string]unitData{
+		"expired pod": {
+			age:          2 * time.Hour,
+			collectStale: true,
+			expected:     criterionTTL,
+		},
+		"young pod": {
+			age:          time.Minute,
+			collectStale: true,
+		},
+		"stale pod": {
+			age:          time.Minute,
+			collectStale: true,
+			stale:        true,
+			expected:     criterionStaleTemplate,
+		},
+		"stale pod, criterion disabled": {
+			age:   time.Minute,
+			stale: true,
+		},
+		"restarted pod": {
+			age:      time.Minute,
+			statuses: []v1.ContainerStatus{{RestartCount: 60}, {RestartCount: 40}},
+			expected: criterionRestarts,
+		},
+		"pod restarted a few times": {
+			age:      time.Minute,
+			statuses: []v1.ContainerStatus{{RestartCount: 3}},
+		},
+		"pod OOM killed recently": {
+			age:      time.Minute,
+			statuses: []v1.ContainerStatus{{LastTerminationState: oomKilled(time.Now().Add(-time.Minute))}},
+			expected: criterionOOMKilled,
+		},
+		"pod being OOM killed": {
+			age:      time.Minute,
+			statuses: []v1.ContainerStatus{{State: oomKilled(time.Now())}},
+			expected: criterionOOMKilled,
+		},
+		"pod OOM killed long ago": {
+			age:      time.Minute,
+			statuses: []v1.ContainerStatus{{LastTerminationState: oomKilled(time.Now().Add(-2 * time.Hour))}},
+		},
+		"pod restarted a few times, max restarts annotated": {
+			age:         time.Minute,
+			statuses:    []v1.ContainerStatus{{RestartCount: 3}},
+			maxRestarts: &fewRestarts,
+			expected:    criterionRestarts,
+		},
+		"restarted pod, criterion disabled by annotation": {
+			age:         time.Minute,
+			statuses:    []v1.ContainerStatus{{RestartCount: 100}},
+			maxRestarts: &noRestarts,
+		},
+		"pod OOM killed long ago, OOM kill window annotated": {
+			age:           time.Minute,
+			statuses:      []v1.ContainerStatus{{LastTerminationState: oomKilled(time.Now().Add(-2 * time.Hour))}},
+			oomKillWindow: &threeHours,
+			expected:      criterionOOMKilled,
+		},
+		"changed config": {
+			age:           time.Minute,
+			collectConfig: true,
+			config:        &k8s.Owner{Kind: k8s.KindSecret, Namespace: "namespace-1", Name: "secret-1"},
+			expected:      criterionConfigChanged,
+		},
+		"changed config, criterion disabled": {
+			age:    time.Minute,
+			config: &k8s.Owner{Kind: k8s.KindSecret, Namespace: "namespace-1", Name: "secret-1"},
+		},
+		"template comparison failed": {
+			age:          time.Minute,
+			collectStale: true,
+			staleErr:     errors.New("failed to get owner"),
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				pod := v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "namespace-1"},
+					Status:     v1.PodStatus{ContainerStatuses: unit.statuses},
+				}
+				k8sMock.On("StaleTemplate", ctx, pod).Return(unit.stale, unit.staleErr)
+				k8sMock.On("ChangedConfig", ctx, pod).Return(unit.config, nil)
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					CollectStaleTemplates: unit.collectStale,
+					CollectConfigChanges:  unit.collectConfig,
+					MaxRestarts:           100,
+					OOMKillWindow:         time.Hour,
+				}, k8sMock)
+				expiration := k8s.Expiration{TTL: time.Hour, MaxRestarts: unit.maxRestarts, OOMKillWindow: unit.oomKillWindow}
+				criterion := d.criterion(ctx, pod, expiration, unit.age, logrus.Fields{})
+
+				assert.Equal(t, unit.expected, criterion)
+				if !unit.collectStale {
+					k8sMock.AssertNotCalled(t, "StaleTemplate", ctx, pod)
+				}
+				if !unit.collectConfig {
+					k8sMock.AssertNotCalled(t, "ChangedConfig", ctx, pod)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestCollectedEvent(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		criterion string
+		emitted   bool
+	}
+
+	data := map[string]unitData{
+		"collected for its ttl": {
+			criterion: criterionTTL,
+		},
+		"collected for its restarts": {
+			criterion: criterionRestarts,
+			emitted:   true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1",
+					criterion: unit.criterion}
+				k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1")).Return(nil).Once()
+				if unit.emitted {
+					k8sMock.On("EmitEvent", ctx, k8s.PodReference("namespace-1", "pod-1"), k8s.EventTypeNormal,
+						eventReasonCollected, mock.Anything).Return(nil).Once()
+				}
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{}, k8sMock)
+				d.evict(ctx, markedPod, logrus.Fields{})
+
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
@@ -0,0 +1,95 @@
+package strategy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// undrainTimeout bounds the opening of a drain gate again, done even once the daemon is stopping.
+const undrainTimeout = 30 * time.Second
+
+// drains keeps the pods being drained, so their drain gate isn't opened again meanwhile.
+type drains struct {
+	mu   sync.Mutex
+	pods map[types.UID]bool
+}
+
+func newDrains() *drains {
+	return &drains{pods: make(map[types.UID]bool)}
+}
+
+func (s *drains) start(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pods[uid] = true
+}
+
+func (s *drains) stop(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pods, uid)
+}
+
+func (s *drains) active(uid types.UID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pods[uid]
+}
+
+// openDrainGates opens the drain gate of the pods declaring it, as they aren't ready until it is open.
+// Pods being drained or deleted are left closed. Gates are opened in paused namespaces too: it only makes pods ready,
+// while keeping them closed would stop new pods from ever becoming ready during a pause.
+func (d *RandomizedDelay) openDrainGates(ctx context.Context, pods []v1.Pod) {
+	for _, pod := range pods {
+		if !k8s.HasDrainGate(pod) || k8s.DrainGateOpen(pod) || pod.ObjectMeta.DeletionTimestamp != nil ||
+			d.drains.active(pod.ObjectMeta.UID) {
+			continue
+		}
+		lFields := logrus.Fields{"namespace": pod.ObjectMeta.Namespace, "pod": pod.ObjectMeta.Name}
+		if err := d.k8sClient.SetDrainGate(ctx, pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, true); err != nil {
+			log.WithFields(lFields).Errorf("error while opening pod's drain gate: %v", err)
+			continue
+		}
+		log.WithFields(lFields).Debug("pod's drain gate opened")
+	}
+}
+
+// drain closes the marked pod's drain gate, then waits for the drain period so the pod is removed from
+// the endpoints before being evicted. It reports whether the pod has been drained.
+func (d *RandomizedDelay) drain(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) bool {
+	d.drains.start(markedPod.uid)
+	if err := d.k8sClient.SetDrainGate(ctx, markedPod.namespace, markedPod.name, false); err != nil {
+		d.drains.stop(markedPod.uid)
+		log.WithFields(lFields).Errorf("error while closing pod's drain gate, skipping pod: %v", err)
+		return false
+	}
+	log.WithFields(lFields).WithField("period", d.defaultSettings.DrainPeriod.Seconds()).Info("draining pod")
+
+	select {
+	case <-time.After(d.defaultSettings.DrainPeriod):
+		return true
+	case <-ctx.Done():
+		d.undrain(ctx, markedPod, lFields)
+		return false
+	}
+}
+
+// undrain opens the drain gate of a drained pod which hasn't been evicted, so it serves traffic again.
+// The gate is opened even once the daemon is stopping, the pod wouldn't be ready otherwise.
+func (d *RandomizedDelay) undrain(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	defer d.drains.stop(markedPod.uid)
+	undrainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), undrainTimeout)
+	defer cancel()
+	if err := d.k8sClient.SetDrainGate(undrainCtx, markedPod.namespace, markedPod.name, true); err != nil {
+		log.WithFields(lFields).Errorf("error while opening pod's drain gate: %v", err)
+		return
+	}
+	log.WithFields(lFields).Info("pod not evicted, drain gate opened again")
+}
@@ -0,0 +1,128 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func gatedPod(name string, gate v1.ConditionStatus) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace-1", UID: types.UID("uid-" + name)},
+		Spec:       v1.PodSpec{ReadinessGates: []v1.PodReadinessGate{{ConditionType: k8s.DrainConditionType}}},
+	}
+	if gate != "" {
+		pod.Status.Conditions = []v1.PodCondition{{Type: k8s.DrainConditionType, Status: gate}}
+	}
+	return pod
+}
+
+func TestOpenDrainGates(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	deleted := gatedPod("deleted", "")
+	deleted.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	pods := []v1.Pod{
+		gatedPod("new", ""),
+		gatedPod("open", v1.ConditionTrue),
+		gatedPod("closed", v1.ConditionFalse),
+		gatedPod("draining", v1.ConditionFalse),
+		deleted,
+		{ObjectMeta: metav1.ObjectMeta{Name: "not-gated", Namespace: "namespace-1"}},
+	}
+	k8sMock.On("SetDrainGate", ctx, "namespace-1", "new", true).Return(nil).Once()
+	k8sMock.On("SetDrainGate", ctx, "namespace-1", "closed", true).Return(nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{}, k8sMock)
+	d.drains.start("uid-draining")
+	d.openDrainGates(ctx, pods)
+
+	k8sMock.AssertExpectations(t)
+}
+
+func TestDrainBeforeEvict(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		evictErr error
+		reopened bool
+	}
+
+	data := map[string]unitData{
+		"pod evicted": {},
+		"eviction blocked": {
+			evictErr: apierrors.NewTooManyRequests("disruption budget", 10),
+			reopened: true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1", drainGate: true}
+
+				k8sMock.On("Paused", "namespace-1").Return(false, "")
+				k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+					UID:               "uid-1",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+				}}, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+				k8sMock.On("SetDrainGate", ctx, "namespace-1", "pod-1", false).Return(nil).Once()
+				k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1")).Return(unit.evictErr).Once()
+				if unit.reopened {
+					k8sMock.On("SetDrainGate", mock.Anything, "namespace-1", "pod-1", true).Return(nil).Once()
+				}
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{DrainPeriod: time.Millisecond}, k8sMock)
+				d.collectMarkedPod(ctx, markedPod)
+
+				k8sMock.AssertExpectations(t)
+				assert.False(t, d.drains.active("uid-1"))
+			}
+		}(unit))
+	}
+}
+
+func TestUndrainedOnceCancelled(t *testing.T) {
+	t.Parallel()
+
+	k8sMock := new(K8sClientMock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1", drainGate: true}
+
+	k8sMock.On("Paused", "namespace-1").Return(false, "")
+	k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		UID:               "uid-1",
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}}, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+	// the daemon is stopped while the pod is being drained
+	k8sMock.On("SetDrainGate", ctx, "namespace-1", "pod-1", false).Return(nil).
+		Run(func(mock.Arguments) { cancel() }).Once()
+	k8sMock.On("SetDrainGate", mock.MatchedBy(func(undrainCtx context.Context) bool {
+		return undrainCtx.Err() == nil
+	}), "namespace-1", "pod-1", true).Return(nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{DrainPeriod: time.Minute}, k8sMock)
+	d.collectMarkedPod(ctx, markedPod)
+
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "EvictPod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.False(t, d.drains.active("uid-1"))
+}
@@ -0,0 +1,35 @@
+package strategy
+
+import (
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	skipReasonBarePod      = "bare-pod"
+	skipReasonDaemonSetPod = "daemonset-pod"
+	skipReasonJobPod       = "job-pod"
+	skipReasonLocalStorage = "local-storage"
+)
+
+// excluded returns why the pod is never collected, empty when it can be.
+func (d *RandomizedDelay) excluded(pod v1.Pod) string {
+	return excludedBy(d.defaultSettings.Exclusions, pod)
+}
+
+// excludedBy returns why the exclusions leave the pod out, empty when they don't.
+func excludedBy(exclusions internal.Exclusions, pod v1.Pod) string {
+	switch kind := k8s.ControllerKind(pod); {
+	case kind == "" && exclusions.BarePods:
+		return skipReasonBarePod
+	case kind == k8s.KindDaemonSet && exclusions.DaemonSetPods:
+		return skipReasonDaemonSetPod
+	case kind == k8s.KindJob && exclusions.JobPods:
+		return skipReasonJobPod
+	}
+	if exclusions.LocalStorage && k8s.HasLocalStorage(pod) {
+		return skipReasonLocalStorage
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	allExclusions = internal.Exclusions{BarePods: true, DaemonSetPods: true, JobPods: true, LocalStorage: true}
+	emptyDir      = v1.Volume{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}
+)
+
+func ownedPod(name, kind string, volumes ...v1.Volume) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "namespace-1",
+			UID:               types.UID("uid-" + name),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		},
+		Spec: v1.PodSpec{Volumes: volumes},
+	}
+	if kind != "" {
+		controller := true
+		pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: "owner-1", Controller: &controller}}
+	}
+	return pod
+}
+
+func TestExcluded(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod            v1.Pod
+		exclusions     internal.Exclusions
+		expectedReason string
+	}
+
+	data := map[string]unitData{
+		"replicaset pod":            {pod: ownedPod("pod-1", k8s.KindReplicaSet), exclusions: allExclusions},
+		"bare pod":                  {pod: ownedPod("pod-1", ""), exclusions: allExclusions, expectedReason: skipReasonBarePod},
+		"bare pod allowed":          {pod: ownedPod("pod-1", ""), exclusions: internal.Exclusions{}},
+		"daemonset pod":             {pod: ownedPod("pod-1", k8s.KindDaemonSet), exclusions: allExclusions, expectedReason: skipReasonDaemonSetPod},
+		"daemonset pod allowed":     {pod: ownedPod("pod-1", k8s.KindDaemonSet), exclusions: internal.Exclusions{BarePods: true}},
+		"job pod":                   {pod: ownedPod("pod-1", k8s.KindJob), exclusions: allExclusions, expectedReason: skipReasonJobPod},
+		"pod with emptyDir":         {pod: ownedPod("pod-1", k8s.KindReplicaSet, emptyDir), exclusions: allExclusions, expectedReason: skipReasonLocalStorage},
+		"pod with emptyDir allowed": {pod: ownedPod("pod-1", k8s.KindReplicaSet, emptyDir), exclusions: internal.Exclusions{JobPods: true}},
+		"pod with configmap volume": {
+			pod: ownedPod("pod-1", k8s.KindReplicaSet, v1.Volume{Name: "config", VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config"}},
+			}}),
+			exclusions: allExclusions,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{Exclusions: unit.exclusions}, new(K8sClientMock))
+
+				if reason := d.excluded(unit.pod); reason != unit.expectedReason {
+					t.Fatalf("expected: %q, got: %q", unit.expectedReason, reason)
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestFindPodsToCollectExclusions(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	pods := []v1.Pod{ownedPod("bare", ""), ownedPod("cache", k8s.KindReplicaSet, emptyDir),
+		ownedPod("job", k8s.KindJob), ownedPod("app", k8s.KindReplicaSet)}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector:   "app=app-1",
+		TTL:        time.Hour,
+		Exclusions: allExclusions,
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 1)
+	assert.Equal("app", (<-d.collector).name)
+	k8sMock.AssertNumberOfCalls(t, "OwnerFromPod", 1)
+}
+
+func TestBarePodCollectedWithGuard(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	bare := ownedPod("bare", "")
+	bare.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "", "").Return([]v1.Pod{bare}, nil)
+	k8sMock.On("GetPod", ctx, "namespace-1", "bare").Return(&bare, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, bare).Return((*k8s.Owner)(nil), nil)
+	k8sMock.On("EvictPod", ctx, "namespace-1", "bare", types.UID("uid-bare")).Return(nil).Once()
+
+	// bare pods aren't excluded, the min ready replicas guard doesn't keep them
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		TTL:              time.Hour,
+		MinReadyReplicas: 1,
+		Exclusions:       internal.Exclusions{BarePods: false},
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 1)
+	d.collectMarkedPod(ctx, *<-d.collector)
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "WorkloadReplicas", mock.Anything, mock.Anything)
+}
+
+func TestPlanExclusions(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	snoozed := ownedPod("snoozed", k8s.KindReplicaSet)
+	snoozed.ObjectMeta.Annotations = map[string]string{
+		k8s.SnoozeUntilAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+	pods := []v1.Pod{ownedPod("bare", ""), ownedPod("daemon", k8s.KindDaemonSet), snoozed,
+		ownedPod("app", k8s.KindReplicaSet)}
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+
+	planned, err := Plan(ctx, &internal.DefaultSettings{
+		Selector:   "app=app-1",
+		TTL:        time.Hour,
+		Exclusions: allExclusions,
+	}, k8sMock)
+	assert.Nil(err)
+
+	skipped := make(map[string]string)
+	for _, p := range planned {
+		skipped[p.Name] = p.Skipped
+	}
+	assert.Equal(map[string]string{"bare": skipReasonBarePod, "daemon": skipReasonDaemonSetPod,
+		"snoozed": k8s.SkipReasonSnoozed, "app": ""}, skipped)
+}
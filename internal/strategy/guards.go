@@ -0,0 +1,112 @@
+package strategy
+
+import (
+	"context"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	skipReasonMinReadyReplicas = "min-ready-replicas"
+	skipReasonPaused           = "paused"
+	skipReasonRecreated        = "recreated"
+	skipReasonSelectorMismatch = "selector-mismatch"
+	skipReasonNotExpired       = "not-expired"
+	skipReasonUnresolvedTTL    = "unresolved-ttl"
+)
+
+// hasEnoughReadyReplicas reports whether evicting the pod keeps at least the minimum
+// number of ready replicas in its workload. The pod's readiness is read from the pod got right before evicting it.
+// Pods without workload, and pods which aren't ready, don't lower the number of ready replicas: whether pods
+// without workload are collected is up to the exclusions.
+func (d *RandomizedDelay) hasEnoughReadyReplicas(ctx context.Context, markedPod namespacedPod, pod v1.Pod,
+	lFields logrus.Fields) bool {
+	minReady := int32(d.defaultSettings.MinReadyReplicas)
+	if markedPod.owner == nil || !k8s.IsPodReady(pod) || minReady <= 0 {
+		return true
+	}
+
+	desired, ready, err := d.k8sClient.WorkloadReplicas(ctx, *markedPod.owner)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while getting workload's replicas, skipping pod: %v", err)
+		return false
+	}
+	if ready-1 < minReady {
+		lFields["owner"] = markedPod.owner.String()
+		lFields["desired"] = desired
+		lFields["ready"] = ready
+		skipPod(&markedPod, skipReasonMinReadyReplicas, lFields)
+		return false
+	}
+	return true
+}
+
+// stillCollectable re-validates a marked pod right before collecting it, as minutes can pass since marking.
+// The pod must be the one which has been marked, still match the selectors and still meet a collection criterion.
+// A pod changed since marking, as told by its resource version, must not have been excluded meanwhile.
+func (d *RandomizedDelay) stillCollectable(ctx context.Context, markedPod namespacedPod, pod v1.Pod,
+	lFields logrus.Fields) bool {
+	if pod.ObjectMeta.UID != markedPod.uid {
+		lFields["uid"] = pod.ObjectMeta.UID
+		skipPod(&markedPod, skipReasonRecreated, lFields)
+		return false
+	}
+	if reason := d.changedSinceMarking(markedPod, pod, lFields); reason != "" {
+		skipPod(&markedPod, reason, lFields)
+		return false
+	}
+
+	selected, err := d.selected(pod)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while matching selectors, skipping pod: %v", err)
+		return false
+	}
+	if !selected {
+		skipPod(&markedPod, skipReasonSelectorMismatch, lFields)
+		return false
+	}
+
+	expiration, err := d.k8sClient.ResolveExpiration(ctx, pod, d.defaultSettings.TTL)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while resolving pod's ttl, skipping pod: %v", err)
+		return false
+	}
+	age := expiration.Age(pod, d.defaultSettings.AgeSource, time.Now())
+	if d.criterion(ctx, pod, expiration, age, lFields) == "" {
+		skipPod(&markedPod, skipReasonNotExpired, lFields)
+		return false
+	}
+	return true
+}
+
+// changedSinceMarking returns why a pod changed since marking is excluded now, e.g. it has been orphaned by its
+// controller, empty when it isn't. Pods unchanged since marking passed the exclusions when marked.
+func (d *RandomizedDelay) changedSinceMarking(markedPod namespacedPod, pod v1.Pod, lFields logrus.Fields) string {
+	if pod.ObjectMeta.ResourceVersion == markedPod.version {
+		return ""
+	}
+	lFields["resourceVersion"] = pod.ObjectMeta.ResourceVersion
+	return d.excluded(pod)
+}
+
+// unstableOwner returns why the pod's owner isn't stable enough to be collected, an empty reason when it is.
+// Owners are checked once per marking cycle.
+func (d *RandomizedDelay) unstableOwner(ctx context.Context, nsPod *namespacedPod, cycle *markingCycle) (string, error) {
+	if !d.defaultSettings.SkipUnstableWorkloads || nsPod.owner == nil {
+		return "", nil
+	}
+	key := nsPod.owner.String()
+	if reason, ok := cycle.unstableOwners[key]; ok {
+		return reason, nil
+	}
+	reason, err := d.k8sClient.WorkloadUnstable(ctx, *nsPod.owner)
+	if err != nil {
+		return "", err
+	}
+	cycle.unstableOwners[key] = reason
+	return reason, nil
+}
@@ -0,0 +1,257 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHasEnoughReadyReplicas(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		markedPod        namespacedPod
+		podReady         bool
+		minReadyReplicas int
+		ready            int32
+		expected         bool
+	}
+
+	owner := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	data := map[string]unitData{
+		"last ready replica": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         true,
+			minReadyReplicas: 1,
+			ready:            1,
+			expected:         false,
+		},
+		"enough ready replicas": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         true,
+			minReadyReplicas: 1,
+			ready:            2,
+			expected:         true,
+		},
+		"higher minimum": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         true,
+			minReadyReplicas: 2,
+			ready:            2,
+			expected:         false,
+		},
+		"pod not ready": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         false,
+			minReadyReplicas: 1,
+			ready:            1,
+			expected:         true,
+		},
+		"guard disabled": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         true,
+			minReadyReplicas: 0,
+			ready:            1,
+			expected:         true,
+		},
+		"pod ready since marking": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1", owner: owner},
+			podReady:         true,
+			minReadyReplicas: 1,
+			ready:            1,
+			expected:         false,
+		},
+		"pod without owner": {
+			markedPod:        namespacedPod{name: "pod-1", namespace: "namespace-1"},
+			podReady:         true,
+			minReadyReplicas: 1,
+			expected:         true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				k8sMock.On("WorkloadReplicas", ctx, *owner).Return(int32(2), unit.ready, nil).Maybe()
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{MinReadyReplicas: unit.minReadyReplicas}, k8sMock)
+
+				pod := v1.Pod{Status: v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady,
+					Status: v1.ConditionFalse}}}}
+				if unit.podReady {
+					pod.Status.Conditions[0].Status = v1.ConditionTrue
+				}
+
+				assert.Equal(t, unit.expected, d.hasEnoughReadyReplicas(ctx, unit.markedPod, pod, logrus.Fields{}))
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
+
+func TestStillCollectable(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod      v1.Pod
+		ttl      time.Duration
+		expected bool
+	}
+
+	markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1"}
+	podWith := func(uid types.UID, labels map[string]string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              "pod-1",
+			Namespace:         "namespace-1",
+			UID:               uid,
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+			OwnerReferences:   ownedPod("pod-1", k8s.KindReplicaSet).ObjectMeta.OwnerReferences,
+		}}
+	}
+	data := map[string]unitData{
+		"same pod": {
+			pod:      podWith("uid-1", map[string]string{"app": "app-1"}),
+			ttl:      time.Hour,
+			expected: true,
+		},
+		"pod recreated": {
+			pod:      podWith("uid-2", map[string]string{"app": "app-1"}),
+			ttl:      time.Hour,
+			expected: false,
+		},
+		"labels changed": {
+			pod:      podWith("uid-1", map[string]string{"app": "app-2"}),
+			ttl:      time.Hour,
+			expected: false,
+		},
+		"ttl changed": {
+			pod:      podWith("uid-1", map[string]string{"app": "app-1"}),
+			ttl:      3 * time.Hour,
+			expected: false,
+		},
+		"changed since marking": {
+			pod: func() v1.Pod {
+				pod := podWith("uid-1", map[string]string{"app": "app-1"})
+				pod.ObjectMeta.ResourceVersion = "2"
+				return pod
+			}(),
+			ttl:      time.Hour,
+			expected: true,
+		},
+		"orphaned since marking": {
+			pod: func() v1.Pod {
+				pod := podWith("uid-1", map[string]string{"app": "app-1"})
+				pod.ObjectMeta.ResourceVersion = "2"
+				pod.ObjectMeta.OwnerReferences = nil
+				return pod
+			}(),
+			ttl:      time.Hour,
+			expected: false,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				k8sMock.On("ResolveExpiration", ctx, unit.pod, time.Hour).Return(k8s.Expiration{TTL: unit.ttl}, nil).Maybe()
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{Selector: "app=app-1", TTL: time.Hour,
+					Exclusions: internal.Exclusions{BarePods: true}}, k8sMock)
+
+				assert.Equal(t, unit.expected, d.stillCollectable(ctx, markedPod, unit.pod, logrus.Fields{}))
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
+
+func TestSkipUnstableOwners(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	rollingOut := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "rolling-out"}
+	stable := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "stable"}
+	pod := func(name string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "namespace-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}}
+	}
+	pods := []v1.Pod{pod("rolling-out-1"), pod("rolling-out-2"), pod("stable-1")}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, pods[0]).Return(rollingOut, nil)
+	k8sMock.On("OwnerFromPod", ctx, pods[1]).Return(rollingOut, nil)
+	k8sMock.On("OwnerFromPod", ctx, pods[2]).Return(stable, nil)
+	// owners are checked once per cycle
+	k8sMock.On("WorkloadUnstable", ctx, *rollingOut).Return(k8s.UnstableRollout, nil).Once()
+	k8sMock.On("WorkloadUnstable", ctx, *stable).Return("", nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector:              "app=app-1",
+		TTL:                   time.Hour,
+		SkipUnstableWorkloads: true,
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 1)
+	assert.Equal("stable-1", (<-d.collector).name)
+	k8sMock.AssertExpectations(t)
+}
+
+func TestStalePodsOfRollingOutOwners(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	rollingOut := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "rolling-out"}
+	pod := func(name string, age time.Duration) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "namespace-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}}
+	}
+	pods := []v1.Pod{pod("stale-1", time.Minute), pod("expired-1", 2*time.Hour)}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("StaleTemplate", ctx, pods[0]).Return(true, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return(rollingOut, nil)
+	k8sMock.On("WorkloadUnstable", ctx, *rollingOut).Return(k8s.UnstableRollout, nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector:              "app=app-1",
+		TTL:                   time.Hour,
+		SkipUnstableWorkloads: true,
+		CollectStaleTemplates: true,
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 1)
+	assert.Equal("stale-1", (<-d.collector).name)
+	k8sMock.AssertExpectations(t)
+}
@@ -0,0 +1,165 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	skipReasonUnhealthyNamespace = "unhealthy-namespace"
+	skipReasonUnhealthyOwner     = "unhealthy-owner"
+)
+
+var (
+	healthGateSuspended = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raccoon_health_gate_suspended",
+			Help: "Whether collection is suspended because of unhealthy pods, owner is empty for a namespace gate",
+		},
+		[]string{"namespace", "owner"})
+)
+
+// podsHealth counts unhealthy pods among a set of pods.
+type podsHealth struct {
+	total     int
+	unhealthy int
+}
+
+func (h *podsHealth) add(pod v1.Pod) {
+	h.total++
+	if pod.Status.Phase == v1.PodPending || !k8s.IsPodReady(pod) {
+		h.unhealthy++
+	}
+}
+
+func (h podsHealth) ratio() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return float64(h.unhealthy) / float64(h.total)
+}
+
+// healthGate tells which namespaces and owners have too many unhealthy pods to be collected.
+type healthGate struct {
+	suspendedNamespaces map[string]bool
+	suspendedOwners     map[string]bool
+}
+
+// evaluateHealth computes the ratio of not ready (or pending) pods per namespace and per owner, among all the pods
+// of the namespaces but only among the selected pods of the owners. Collection is suspended where this ratio exceeds
+// the configured maximum.
+func (d *RandomizedDelay) evaluateHealth(ctx context.Context, pods []v1.Pod) healthGate {
+	gate := healthGate{
+		suspendedNamespaces: make(map[string]bool),
+		suspendedOwners:     make(map[string]bool),
+	}
+	maxRatio := d.defaultSettings.MaxUnhealthyRatio
+	if maxRatio <= 0 {
+		return gate
+	}
+	namespaces := d.namespacesHealth(ctx, pods)
+	owners := make(map[string]*podsHealth)
+	ownersNamespace := make(map[string]string)
+	for _, pod := range pods {
+		if !inWorkload(pod) {
+			continue
+		}
+		owner, err := d.k8sClient.OwnerFromPod(ctx, pod)
+		if err != nil || owner == nil {
+			continue
+		}
+		healthOf(owners, owner.String()).add(pod)
+		ownersNamespace[owner.String()] = owner.Namespace
+	}
+
+	healthGateSuspended.Reset()
+	gate.suspendedNamespaces = suspendUnhealthy(namespaces, maxRatio, "namespace",
+		func(namespace string) prometheus.Labels {
+			return prometheus.Labels{"namespace": namespace, "owner": ""}
+		})
+	gate.suspendedOwners = suspendUnhealthy(owners, maxRatio, "owner", func(owner string) prometheus.Labels {
+		return prometheus.Labels{"namespace": ownersNamespace[owner], "owner": owner}
+	})
+	return gate
+}
+
+// namespacesHealth counts the unhealthy pods of the namespaces running selected pods, among all their pods.
+// A namespace whose pods can't be listed is evaluated on its selected pods only.
+func (d *RandomizedDelay) namespacesHealth(ctx context.Context, pods []v1.Pod) map[string]*podsHealth {
+	selected := make(map[string][]v1.Pod)
+	for _, pod := range pods {
+		selected[pod.Namespace] = append(selected[pod.Namespace], pod)
+	}
+	namespaces := make(map[string]*podsHealth, len(selected))
+	for namespace, nsPods := range selected {
+		all, err := d.k8sClient.ListPods(ctx, namespace, "", "")
+		if err != nil {
+			log.WithField("namespace", namespace).
+				Errorf("error while listing namespace's pods, evaluating its selected pods only: %v", err)
+			all = nsPods
+		}
+		health := &podsHealth{}
+		for _, pod := range all {
+			if inWorkload(pod) {
+				health.add(pod)
+			}
+		}
+		namespaces[namespace] = health
+	}
+	return namespaces
+}
+
+// inWorkload reports whether the pod counts in its workload's health: completed pods and pods being deleted
+// aren't part of the workloads anymore.
+func inWorkload(pod v1.Pod) bool {
+	return pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed &&
+		pod.ObjectMeta.DeletionTimestamp == nil
+}
+
+// suspendUnhealthy tells which namespaces, or owners, have a ratio of unhealthy pods above the maximum,
+// and exposes it with the labels of each one.
+func suspendUnhealthy(healths map[string]*podsHealth, maxRatio float64, field string,
+	labels func(key string) prometheus.Labels) map[string]bool {
+	suspended := make(map[string]bool, len(healths))
+	for key, health := range healths {
+		suspended[key] = health.ratio() > maxRatio
+		healthGateSuspended.With(labels(key)).Set(boolToFloat(suspended[key]))
+		if suspended[key] {
+			log.WithField(field, key).WithField("ratio", health.ratio()).
+				Warnf("too many unhealthy pods in %s, suspending collection", field)
+		}
+	}
+	return suspended
+}
+
+// suspended reports whether the marked pod must not be collected, along with the reason.
+func (g healthGate) suspended(nsPod *namespacedPod) (bool, string) {
+	if g.suspendedNamespaces[nsPod.namespace] {
+		return true, skipReasonUnhealthyNamespace
+	}
+	if nsPod.owner != nil && g.suspendedOwners[nsPod.owner.String()] {
+		return true, skipReasonUnhealthyOwner
+	}
+	return false, ""
+}
+
+func healthOf(healths map[string]*podsHealth, key string) *podsHealth {
+	health, ok := healths[key]
+	if !ok {
+		health = &podsHealth{}
+		healths[key] = health
+	}
+	return health
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func podWithHealth(name, namespace string, phase v1.PodPhase, ready bool) v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status: v1.PodStatus{
+			Phase:      phase,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestEvaluateHealth(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	crashing := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "crashing"}
+	healthy := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "healthy"}
+
+	pods := []v1.Pod{
+		// namespace-1: 2 unhealthy pods out of 6, all in the crashing owner
+		podWithHealth("crashing-1", "namespace-1", v1.PodRunning, false),
+		podWithHealth("crashing-2", "namespace-1", v1.PodPending, false),
+		podWithHealth("crashing-3", "namespace-1", v1.PodRunning, true),
+		podWithHealth("healthy-1", "namespace-1", v1.PodRunning, true),
+		podWithHealth("healthy-2", "namespace-1", v1.PodRunning, true),
+		podWithHealth("healthy-3", "namespace-1", v1.PodRunning, true),
+		// namespace-2: 1 unhealthy pod out of 2, completed pods are ignored
+		podWithHealth("bare-1", "namespace-2", v1.PodRunning, true),
+		podWithHealth("bare-2", "namespace-2", v1.PodPending, false),
+		podWithHealth("job-1", "namespace-2", v1.PodSucceeded, false),
+		podWithHealth("job-2", "namespace-2", v1.PodSucceeded, false),
+		// namespace-3: the selected pod is healthy, but 2 other pods out of 3 aren't
+		podWithHealth("selected-1", "namespace-3", v1.PodRunning, true),
+	}
+	namespacePods := func(namespace string) []v1.Pod {
+		var nsPods []v1.Pod
+		for _, pod := range pods {
+			if pod.Namespace == namespace {
+				nsPods = append(nsPods, pod)
+			}
+		}
+		return nsPods
+	}
+	k8sMock.On("ListPods", ctx, "namespace-1", "", "").Return(namespacePods("namespace-1"), nil)
+	k8sMock.On("ListPods", ctx, "namespace-2", "", "").Return(namespacePods("namespace-2"), nil)
+	k8sMock.On("ListPods", ctx, "namespace-3", "", "").Return(append(namespacePods("namespace-3"),
+		podWithHealth("other-1", "namespace-3", v1.PodRunning, false),
+		podWithHealth("other-2", "namespace-3", v1.PodPending, false)), nil)
+	for _, pod := range pods {
+		name := pod.Name
+		var owner *k8s.Owner
+		switch name[:len(name)-2] {
+		case "crashing":
+			owner = crashing
+		case "healthy":
+			owner = healthy
+		}
+		k8sMock.On("OwnerFromPod", ctx, mock.MatchedBy(func(p v1.Pod) bool { return p.Name == name })).
+			Return(owner, nil).Maybe()
+	}
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{MaxUnhealthyRatio: 0.4}, k8sMock)
+	gate := d.evaluateHealth(ctx, pods)
+
+	suspended, reason := gate.suspended(&namespacedPod{namespace: "namespace-1", owner: crashing})
+	assert.True(suspended)
+	assert.Equal(skipReasonUnhealthyOwner, reason)
+
+	suspended, _ = gate.suspended(&namespacedPod{namespace: "namespace-1", owner: healthy})
+	assert.False(suspended)
+
+	suspended, reason = gate.suspended(&namespacedPod{namespace: "namespace-2"})
+	assert.True(suspended)
+	assert.Equal(skipReasonUnhealthyNamespace, reason)
+
+	suspended, reason = gate.suspended(&namespacedPod{namespace: "namespace-3"})
+	assert.True(suspended)
+	assert.Equal(skipReasonUnhealthyNamespace, reason)
+
+	// disabled gate
+	d = newRandomizedDelay(0, &internal.DefaultSettings{MaxUnhealthyRatio: 0}, new(K8sClientMock))
+	suspended, _ = d.evaluateHealth(ctx, pods).suspended(&namespacedPod{namespace: "namespace-2"})
+	assert.False(suspended)
+}
@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	skipReasonInvalidHook = "invalid-pre-evict-hook"
+
+	eventReasonHookSucceeded = "RaccoonPreEvictHook"
+	eventReasonHookFailed    = "RaccoonPreEvictHookFailed"
+
+	// pre-evict hook results
+	hookSuccess = "success"
+	hookFailure = "failure"
+	hookTimeout = "timeout"
+)
+
+var (
+	preEvictHooks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_pre_evict_hooks_total",
+			Help: "The total number of pre-evict hooks called, by result (success, failure or timeout)",
+		},
+		[]string{"namespace", "result"})
+)
+
+// callPreEvictHook notifies the application that its pod is about to be evicted, and waits for a 2xx response
+// or the hook timeout. The pod is evicted whatever the result, which is recorded in an event and a metric.
+func (d *RandomizedDelay) callPreEvictHook(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	lFields["hook"] = markedPod.preEvictHook
+	result, err := d.preEvictHook(ctx, markedPod.preEvictHook)
+	preEvictHooks.With(prometheus.Labels{"namespace": markedPod.namespace, "result": result}).Inc()
+
+	eventType, reason := k8s.EventTypeNormal, eventReasonHookSucceeded
+	message := fmt.Sprintf("pre-evict hook %s succeeded", markedPod.preEvictHook)
+	if result == hookSuccess {
+		log.WithFields(lFields).Info("pre-evict hook succeeded")
+	} else {
+		log.WithFields(lFields).WithField("result", result).Warnf("pre-evict hook failed, evicting pod anyway: %v", err)
+		eventType, reason = k8s.EventTypeWarning, eventReasonHookFailed
+		message = fmt.Sprintf("pre-evict hook %s failed (%s), evicting pod anyway: %v",
+			markedPod.preEvictHook, result, err)
+	}
+
+	err = d.k8sClient.EmitEvent(ctx, k8s.PodReference(markedPod.namespace, markedPod.name), eventType, reason, message)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+	}
+}
+
+// newHookClient returns the client calling the pre-evict hooks. It doesn't follow redirects,
+// so a pod can't make raccoon post to another address.
+func newHookClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// preEvictHook posts to the hook's url, it returns the result of the call.
+func (d *RandomizedDelay) preEvictHook(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.defaultSettings.PreEvictHookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return hookFailure, err
+	}
+	resp, err := d.hookClient.Do(req)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return hookTimeout, ctx.Err()
+	}
+	if err != nil {
+		return hookFailure, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return hookFailure, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return hookSuccess, nil
+}
@@ -0,0 +1,94 @@
+package strategy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPreEvictHook(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		handler   http.HandlerFunc
+		eventType string
+		reason    string
+	}
+
+	data := map[string]unitData{
+		"hook succeeded": {
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			eventType: k8s.EventTypeNormal,
+			reason:    eventReasonHookSucceeded,
+		},
+		"hook failed": {
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			eventType: k8s.EventTypeWarning,
+			reason:    eventReasonHookFailed,
+		},
+		"hook redirected": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/redirected", http.StatusTemporaryRedirect)
+			},
+			eventType: k8s.EventTypeWarning,
+			reason:    eventReasonHookFailed,
+		},
+		"hook timed out": {
+			handler:   func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) },
+			eventType: k8s.EventTypeWarning,
+			reason:    eventReasonHookFailed,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/redirected" {
+						w.WriteHeader(http.StatusNoContent)
+						return
+					}
+					if r.Method != http.MethodPost || r.URL.Path != "/pre-stop" {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					unit.handler(w, r)
+				}))
+				defer server.Close()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1",
+					preEvictHook: server.URL + "/pre-stop"}
+
+				k8sMock.On("Paused", "namespace-1").Return(false, "")
+				k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+					UID:               "uid-1",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+				}}, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+				k8sMock.On("EmitEvent", ctx, k8s.PodReference("namespace-1", "pod-1"), unit.eventType, unit.reason,
+					mock.Anything).Return(nil).Once()
+				k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1")).Return(nil).Once()
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					PreEvictHookTimeout: 50 * time.Millisecond,
+				}, k8sMock)
+				d.collectMarkedPod(ctx, markedPod)
+
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
@@ -0,0 +1,79 @@
+package strategy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// memoryPressure remembers since when pods have been using more memory than the ratio of their limit.
+type memoryPressure struct {
+	mu    sync.Mutex
+	since map[types.UID]time.Time
+}
+
+func newMemoryPressure() *memoryPressure {
+	return &memoryPressure{since: make(map[types.UID]time.Time)}
+}
+
+// update records the pods above the ratio of their memory limit, and forgets the other ones.
+func (m *memoryPressure) update(above map[types.UID]bool, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for uid := range m.since {
+		if !above[uid] {
+			delete(m.since, uid)
+		}
+	}
+	for uid := range above {
+		if _, ok := m.since[uid]; !ok {
+			m.since[uid] = now
+		}
+	}
+}
+
+// sustained reports whether the pod has been above the ratio of its memory limit for at least the period.
+func (m *memoryPressure) sustained(uid types.UID, period time.Duration, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	since, ok := m.since[uid]
+	return ok && now.Sub(since) >= period
+}
+
+// trackMemory reads the working set of the pods from the metrics api, and records the ones above
+// the ratio of their memory limit. The pressure is kept as is when the metrics can't be read.
+func (d *RandomizedDelay) trackMemory(ctx context.Context, pods []v1.Pod) {
+	workingSets, err := d.k8sClient.PodsWorkingSet(ctx, d.defaultSettings.Namespace, d.defaultSettings.Selector)
+	if err != nil {
+		log.Errorf("error while reading pods' memory, memory pressure not updated: %v", err)
+		return
+	}
+	above := make(map[types.UID]bool)
+	for _, pod := range pods {
+		podName := types.NamespacedName{Namespace: pod.ObjectMeta.Namespace, Name: pod.ObjectMeta.Name}
+		if aboveMemoryLimit(pod, workingSets[podName], d.defaultSettings.MemoryLimitRatio) {
+			above[pod.ObjectMeta.UID] = true
+		}
+	}
+	d.memory.update(above, time.Now())
+}
+
+// aboveMemoryLimit reports whether a container of the pod uses more than the ratio of its memory limit.
+// Containers without memory limit are ignored.
+func aboveMemoryLimit(pod v1.Pod, workingSets map[string]int64, ratio float64) bool {
+	for _, container := range pod.Spec.Containers {
+		limit, ok := container.Resources.Limits[v1.ResourceMemory]
+		workingSet, measured := workingSets[container.Name]
+		if !ok || limit.IsZero() || !measured {
+			continue
+		}
+		if float64(workingSet) >= ratio*float64(limit.Value()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func memoryLimitedPod(name string, limit string) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace-1", UID: types.UID("uid-" + name)},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+	}
+	if limit != "" {
+		pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{v1.ResourceMemory: resource.MustParse(limit)}
+	}
+	return pod
+}
+
+func TestAboveMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		pod         v1.Pod
+		workingSets map[string]int64
+		expected    bool
+	}
+
+	data := map[string]unitData{
+		"above the ratio": {
+			pod:         memoryLimitedPod("pod-1", "1Gi"),
+			workingSets: map[string]int64{"app": 950 << 20},
+			expected:    true,
+		},
+		"below the ratio": {
+			pod:         memoryLimitedPod("pod-1", "1Gi"),
+			workingSets: map[string]int64{"app": 512 << 20},
+		},
+		"without memory limit": {
+			pod:         memoryLimitedPod("pod-1", ""),
+			workingSets: map[string]int64{"app": 8 << 30},
+		},
+		"without metrics": {
+			pod: memoryLimitedPod("pod-1", "1Gi"),
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, unit.expected, aboveMemoryLimit(unit.pod, unit.workingSets, 0.9))
+			}
+		}(unit))
+	}
+}
+
+func TestMemoryPressure(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	pods := []v1.Pod{memoryLimitedPod("pod-1", "1Gi"), memoryLimitedPod("pod-2", "1Gi")}
+	workingSets := map[types.NamespacedName]map[string]int64{
+		{Namespace: "namespace-1", Name: "pod-1"}: {"app": 1 << 30},
+		{Namespace: "namespace-1", Name: "pod-2"}: {"app": 1 << 30},
+	}
+	k8sMock.On("PodsWorkingSet", ctx, "namespace-1", "app=nginx").Return(workingSets, nil).Once()
+	k8sMock.On("PodsWorkingSet", ctx, "namespace-1", "app=nginx").
+		Return(map[types.NamespacedName]map[string]int64(nil), errors.New("metrics unavailable")).Once()
+	k8sMock.On("PodsWorkingSet", ctx, "namespace-1", "app=nginx").Return(map[types.NamespacedName]map[string]int64{
+		{Namespace: "namespace-1", Name: "pod-1"}: {"app": 1 << 30},
+	}, nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Namespace:        "namespace-1",
+		Selector:         "app=nginx",
+		MemoryLimitRatio: 0.9,
+	}, k8sMock)
+	d.trackMemory(ctx, pods)
+	assert.True(d.memory.sustained("uid-pod-1", 0, time.Now()))
+	assert.False(d.memory.sustained("uid-pod-1", time.Hour, time.Now()))
+	assert.True(d.memory.sustained("uid-pod-1", time.Hour, time.Now().Add(time.Hour)))
+
+	// unavailable metrics keep the pressure
+	d.trackMemory(ctx, pods)
+	assert.True(d.memory.sustained("uid-pod-2", 0, time.Now()))
+
+	d.trackMemory(ctx, pods)
+	assert.True(d.memory.sustained("uid-pod-1", 0, time.Now()))
+	assert.False(d.memory.sustained("uid-pod-2", 0, time.Now()))
+	k8sMock.AssertExpectations(t)
+}
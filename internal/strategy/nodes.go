@@ -0,0 +1,215 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	eventReasonNodeRecycling = "RaccoonNodeRecycling"
+
+	// criterionNodeTTL labels the pods evicted from recycled nodes
+	criterionNodeTTL = "node-ttl"
+)
+
+var (
+	nodesRecycled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_nodes_recycled_total",
+			Help: "The total number of nodes older than their ttl cordoned, then deleted, by action",
+		},
+		[]string{"action"})
+	nodesRecycling = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "raccoon_nodes_recycling",
+			Help: "The number of nodes cordoned by raccoon, drained or not, at the last check",
+		})
+)
+
+type nodeClient interface {
+	ListNodes(ctx context.Context, labelSelector string) ([]v1.Node, error)
+	CordonNode(ctx context.Context, name string) error
+	NodePods(ctx context.Context, name string) ([]v1.Pod, error)
+	EvictPod(ctx context.Context, namespace, name string, uid types.UID) error
+	DeleteNode(ctx context.Context, name string) error
+	EmitEvent(ctx context.Context, involved v1.ObjectReference, eventType, reason, message string) error
+	Paused(namespace string) (bool, string)
+}
+
+// NodeRecycler cordons the nodes older than their ttl, the oldest first, and drains them
+// by evicting their pods. Drained nodes are optionally deleted.
+type NodeRecycler struct {
+	settings  *internal.NodeSettings
+	k8sClient nodeClient
+}
+
+// InitNodeRecycler initializes NodeRecycler struct.
+func InitNodeRecycler(settings *internal.NodeSettings, k8sClient nodeClient) *NodeRecycler {
+	return &NodeRecycler{
+		settings:  settings,
+		k8sClient: k8sClient,
+	}
+}
+
+// Run keeps draining the nodes being recycled, then cordons the oldest nodes older than the ttl
+// as long as fewer than the max concurrent nodes are being recycled. A node cordoned by raccoon
+// counts until it is deleted or uncordoned, drained or not, and a node uncordoned while being recycled
+// is left alone. Nothing is done while raccoon is paused cluster-wide.
+func (r *NodeRecycler) Run(ctx context.Context) error {
+	if paused, reason := r.k8sClient.Paused(""); paused {
+		log.WithField("reason", reason).Info("raccoon paused cluster-wide, skipping node recycling")
+		return nil
+	}
+	nodes, err := r.k8sClient.ListNodes(ctx, r.settings.Selector)
+	if err != nil {
+		return err
+	}
+
+	recycled := r.drainNodes(ctx, nodes)
+	for _, node := range nodes {
+		if recycled >= r.settings.MaxConcurrentNodes {
+			break
+		}
+		if r.cordonNode(ctx, node) {
+			recycled++
+		}
+	}
+	nodesRecycling.Set(float64(recycled))
+	return nil
+}
+
+// drainNodes drains the nodes being recycled and returns how many of them are still there.
+func (r *NodeRecycler) drainNodes(ctx context.Context, nodes []v1.Node) int {
+	recycled := 0
+	for _, node := range nodes {
+		if recycling(node) && !r.drainNode(ctx, node) {
+			recycled++
+		}
+	}
+	return recycled
+}
+
+// cordonNode cordons the node, then starts draining it, when it is older than the ttl and not cordoned yet.
+// It reports whether the node is now being recycled.
+func (r *NodeRecycler) cordonNode(ctx context.Context, node v1.Node) bool {
+	age := time.Since(node.ObjectMeta.CreationTimestamp.Time)
+	if node.ObjectMeta.Annotations[k8s.RecyclingAnnotation] != "" || node.Spec.Unschedulable ||
+		age <= r.settings.TTL {
+		return false
+	}
+	lFields := logrus.Fields{"node": node.ObjectMeta.Name, "age": age.Seconds()}
+	if r.settings.DryRun {
+		log.WithFields(lFields).Info("dry-run, node should have been cordoned")
+		return true
+	}
+	if err := r.k8sClient.CordonNode(ctx, node.ObjectMeta.Name); err != nil {
+		log.WithFields(lFields).Errorf("error while cordoning node: %v", err)
+		return true
+	}
+	log.WithFields(lFields).Info("node older than ttl, node cordoned")
+	nodesRecycled.With(prometheus.Labels{"action": "cordon"}).Inc()
+	message := fmt.Sprintf("node is %v old, it is cordoned and drained by raccoon", age.Truncate(time.Second))
+	err := r.k8sClient.EmitEvent(ctx, k8s.Owner{Kind: k8s.KindNode, Name: node.ObjectMeta.Name}.Reference(),
+		k8s.EventTypeNormal, eventReasonNodeRecycling, message)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+	}
+	return !r.drainNode(ctx, node)
+}
+
+// recycling reports whether the node has been cordoned by raccoon and is still cordoned.
+func recycling(node v1.Node) bool {
+	return node.ObjectMeta.Annotations[k8s.RecyclingAnnotation] != "" && node.Spec.Unschedulable
+}
+
+// drainNode evicts the pods of the node, evictions refused by a disruption budget are retried at the next check.
+// Pods in a paused namespace, opted out by their annotations, or excluded, e.g. bare pods or pods with local storage,
+// are left on the node and keep it from being drained.
+// It reports whether the node is drained and deleted, drained nodes are only deleted when enabled.
+func (r *NodeRecycler) drainNode(ctx context.Context, node v1.Node) bool {
+	lFields := logrus.Fields{"node": node.ObjectMeta.Name}
+	pods, err := r.k8sClient.NodePods(ctx, node.ObjectMeta.Name)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while listing node's pods: %v", err)
+		return false
+	}
+	if len(pods) == 0 {
+		return r.drained(ctx, node, lFields)
+	}
+
+	now := time.Now()
+	for _, pod := range pods {
+		podFields := logrus.Fields{"node": node.ObjectMeta.Name, "namespace": pod.ObjectMeta.Namespace,
+			"pod": pod.ObjectMeta.Name}
+		if pod.ObjectMeta.DeletionTimestamp != nil {
+			log.WithFields(podFields).Debug("pod terminating, waiting for it")
+			continue
+		}
+		if reason := r.kept(pod, now, podFields); reason != "" {
+			skipPod(&namespacedPod{namespace: pod.ObjectMeta.Namespace}, reason, podFields)
+			continue
+		}
+		r.evictPod(ctx, pod, podFields)
+	}
+	return false
+}
+
+// kept returns the reason why the pod must be left on its node, empty when it can be evicted.
+func (r *NodeRecycler) kept(pod v1.Pod, now time.Time, podFields logrus.Fields) string {
+	if paused, reason := r.k8sClient.Paused(pod.ObjectMeta.Namespace); paused {
+		podFields["reason"] = reason
+		return skipReasonPaused
+	}
+	if optedOut, reason := k8s.OptedOut(pod, now); optedOut {
+		return reason
+	}
+	return excludedBy(r.settings.Exclusions, pod)
+}
+
+// evictPod evicts a pod of a node being drained.
+func (r *NodeRecycler) evictPod(ctx context.Context, pod v1.Pod, podFields logrus.Fields) {
+	if r.settings.DryRun {
+		log.WithFields(podFields).Debug("dry-run, pod should have been evicted")
+		return
+	}
+	err := r.k8sClient.EvictPod(ctx, pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, pod.ObjectMeta.UID)
+	if apierrors.IsTooManyRequests(err) {
+		skipPod(&namespacedPod{namespace: pod.ObjectMeta.Namespace}, skipReasonDisruptionBudget, podFields)
+	} else if err != nil {
+		log.WithFields(podFields).Errorf("error while evicting node's pod: %v", err)
+	} else {
+		log.WithFields(podFields).Info("node's pod evicted")
+		podsDeleted.With(prometheus.Labels{"namespace": pod.ObjectMeta.Namespace, "criterion": criterionNodeTTL}).Inc()
+	}
+}
+
+// drained deletes a drained node when enabled, and reports whether it was deleted.
+// A drained node which isn't deleted stays cordoned, and keeps counting as being recycled.
+func (r *NodeRecycler) drained(ctx context.Context, node v1.Node, lFields logrus.Fields) bool {
+	if !r.settings.DeleteNodes {
+		log.WithFields(lFields).Debug("node drained")
+		return false
+	}
+	if r.settings.DryRun {
+		log.WithFields(lFields).Info("dry-run, node should have been deleted")
+		return false
+	}
+	if err := r.k8sClient.DeleteNode(ctx, node.ObjectMeta.Name); err != nil {
+		log.WithFields(lFields).Errorf("error while deleting node: %v", err)
+		return false
+	}
+	log.WithFields(lFields).Info("node drained, node deleted")
+	nodesRecycled.With(prometheus.Labels{"action": "delete"}).Inc()
+	return true
+}
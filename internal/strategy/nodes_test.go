@@ -0,0 +1,177 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func agedNode(name string, age time.Duration, recycling bool) v1.Node {
+	node := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(time.Now().Add(-age))}}
+	if recycling {
+		node.ObjectMeta.Annotations = map[string]string{k8s.RecyclingAnnotation: "2026-10-19T10:00:00Z"}
+		node.Spec.Unschedulable = true
+	}
+	return node
+}
+
+func nodePod(name string) v1.Pod {
+	return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace-1", UID: types.UID("uid-" + name)}}
+}
+
+func TestRecycleNodes(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		nodes       []v1.Node
+		pods        map[string][]v1.Pod
+		drained     []string
+		deleteNodes bool
+		exclusions  internal.Exclusions
+		paused      bool
+		pausedNs    []string
+		cordoned    []string
+		evicted     []string
+		deleted     []string
+	}
+
+	data := map[string]unitData{
+		"oldest expired node cordoned and drained": {
+			nodes: []v1.Node{agedNode("node-1", 10*24*time.Hour, false), agedNode("node-2", 8*24*time.Hour, false),
+				agedNode("node-3", time.Hour, false)},
+			pods:     map[string][]v1.Pod{"node-1": {nodePod("pod-1"), nodePod("pod-2")}},
+			cordoned: []string{"node-1"},
+			evicted:  []string{"pod-1", "pod-2"},
+		},
+		"node being drained": {
+			nodes:   []v1.Node{agedNode("node-1", 10*24*time.Hour, true), agedNode("node-2", 8*24*time.Hour, false)},
+			pods:    map[string][]v1.Pod{"node-1": {nodePod("pod-1")}},
+			evicted: []string{"pod-1"},
+		},
+		"drained node deleted, next node cordoned": {
+			nodes:       []v1.Node{agedNode("node-1", 10*24*time.Hour, true), agedNode("node-2", 8*24*time.Hour, false)},
+			pods:        map[string][]v1.Pod{"node-2": {nodePod("pod-2")}},
+			deleteNodes: true,
+			drained:     []string{"node-1"},
+			deleted:     []string{"node-1"},
+			cordoned:    []string{"node-2"},
+			evicted:     []string{"pod-2"},
+		},
+		"drained node kept, next node not cordoned": {
+			nodes:   []v1.Node{agedNode("node-1", 10*24*time.Hour, true), agedNode("node-2", 8*24*time.Hour, false)},
+			drained: []string{"node-1"},
+		},
+		"paused cluster-wide": {
+			nodes:  []v1.Node{agedNode("node-1", 10*24*time.Hour, true), agedNode("node-2", 8*24*time.Hour, false)},
+			paused: true,
+		},
+		"pods in paused namespace or opted out kept": {
+			nodes: []v1.Node{agedNode("node-1", 10*24*time.Hour, true)},
+			pods: map[string][]v1.Pod{"node-1": {nodePod("pod-1"), func() v1.Pod {
+				pod := nodePod("pod-2")
+				pod.ObjectMeta.Annotations = map[string]string{k8s.SkipAnnotation: "true"}
+				return pod
+			}(), func() v1.Pod {
+				pod := nodePod("pod-3")
+				pod.ObjectMeta.Namespace = "namespace-2"
+				return pod
+			}()}},
+			pausedNs: []string{"namespace-2"},
+			evicted:  []string{"pod-1"},
+		},
+		"bare pods and pods with local storage kept": {
+			nodes: []v1.Node{agedNode("node-1", 10*24*time.Hour, true)},
+			pods: map[string][]v1.Pod{"node-1": {nodePod("pod-1"), ownedPod("pod-2", k8s.KindReplicaSet),
+				ownedPod("pod-3", k8s.KindReplicaSet, v1.Volume{Name: "cache",
+					VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}})}},
+			exclusions: internal.Exclusions{BarePods: true, LocalStorage: true},
+			evicted:    []string{"pod-2"},
+		},
+		"node uncordoned while recycled": {
+			nodes: []v1.Node{func() v1.Node {
+				node := agedNode("node-1", 10*24*time.Hour, true)
+				node.Spec.Unschedulable = false
+				return node
+			}()},
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				ctx := context.Background()
+				k8sMock := new(K8sClientMock)
+				k8sMock.On("Paused", "").Return(unit.paused, "configmap raccoon/raccoon-pause")
+				for _, namespace := range unit.pausedNs {
+					k8sMock.On("Paused", namespace).Return(true, "configmap raccoon/raccoon-pause")
+				}
+				k8sMock.On("Paused", mock.Anything).Return(false, "")
+				if !unit.paused {
+					k8sMock.On("ListNodes", ctx, "node-pool=spot").Return(unit.nodes, nil)
+				}
+				for node, pods := range unit.pods {
+					k8sMock.On("NodePods", ctx, node).Return(pods, nil)
+				}
+				for _, node := range unit.drained {
+					k8sMock.On("NodePods", ctx, node).Return([]v1.Pod{}, nil)
+				}
+				for _, node := range unit.cordoned {
+					k8sMock.On("CordonNode", ctx, node).Return(nil).Once()
+					k8sMock.On("EmitEvent", ctx, k8s.Owner{Kind: k8s.KindNode, Name: node}.Reference(),
+						k8s.EventTypeNormal, eventReasonNodeRecycling, mock.Anything).Return(nil).Once()
+				}
+				for _, pod := range unit.evicted {
+					k8sMock.On("EvictPod", ctx, "namespace-1", pod, types.UID("uid-"+pod)).Return(nil).Once()
+				}
+				for _, node := range unit.deleted {
+					k8sMock.On("DeleteNode", ctx, node).Return(nil).Once()
+				}
+
+				r := InitNodeRecycler(&internal.NodeSettings{
+					Selector:           "node-pool=spot",
+					TTL:                7 * 24 * time.Hour,
+					DeleteNodes:        unit.deleteNodes,
+					MaxConcurrentNodes: 1,
+					Exclusions:         unit.exclusions,
+				}, k8sMock)
+				if err := r.Run(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				k8sMock.AssertExpectations(t)
+				k8sMock.AssertNumberOfCalls(t, "CordonNode", len(unit.cordoned))
+				k8sMock.AssertNumberOfCalls(t, "EvictPod", len(unit.evicted))
+			}
+		}(unit))
+	}
+}
+
+func TestDrainNodeBlocked(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	terminating := nodePod("pod-2")
+	terminating.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("NodePods", ctx, "node-1").Return([]v1.Pod{nodePod("pod-1"), terminating}, nil)
+	k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-pod-1")).
+		Return(apierrors.NewTooManyRequests("disruption budget", 10)).Once()
+
+	r := InitNodeRecycler(&internal.NodeSettings{DeleteNodes: true, MaxConcurrentNodes: 1}, k8sMock)
+	if r.drainNode(ctx, agedNode("node-1", 10*24*time.Hour, true)) {
+		t.Fatal("expected node not to be drained")
+	}
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "DeleteNode", ctx, "node-1")
+}
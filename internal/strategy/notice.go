@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	eventReasonEvictionScheduled = "RaccoonEvictionScheduled"
+
+	// noticeTolerance absorbs the drift of the scheduled date between checks, as ages are truncated to the second.
+	noticeTolerance = time.Minute
+)
+
+// noticeEviction annotates a pod expiring within the eviction notice with the date it is collected at,
+// so the application can prepare, and emits a warning event on the pod. Pods in a paused namespace aren't noticed.
+// The pod is annotated again only when this date changes, e.g. when its ttl is updated.
+func (d *RandomizedDelay) noticeEviction(ctx context.Context, pod v1.Pod, remaining time.Duration,
+	lFields logrus.Fields) {
+	if d.defaultSettings.EvictionNotice == 0 || remaining > d.defaultSettings.EvictionNotice {
+		return
+	}
+	if optedOut, _ := k8s.OptedOut(pod, time.Now()); optedOut || d.excluded(pod) != "" {
+		return
+	}
+	if paused, _ := d.k8sClient.Paused(pod.ObjectMeta.Namespace); paused {
+		return
+	}
+	evictAt := time.Now().Add(remaining).Truncate(time.Second)
+	if noticed(pod, evictAt) {
+		return
+	}
+
+	lFields["evictAt"] = evictAt.UTC().Format(time.RFC3339)
+	if d.defaultSettings.DryRun {
+		log.WithFields(lFields).Debug("dry-run, pod should have been noticed of its eviction")
+		return
+	}
+	err := d.k8sClient.AnnotateEvictAt(ctx, pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, evictAt)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while annotating pod with its eviction date: %v", err)
+		return
+	}
+	log.WithFields(lFields).Info("pod noticed of its eviction")
+
+	message := fmt.Sprintf("pod is scheduled to be collected from %s", evictAt.UTC().Format(time.RFC3339))
+	err = d.k8sClient.EmitEvent(ctx, k8s.PodReference(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name),
+		k8s.EventTypeWarning, eventReasonEvictionScheduled, message)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+	}
+}
+
+// noticed reports whether the pod is already annotated with the given eviction date.
+func noticed(pod v1.Pod, evictAt time.Time) bool {
+	annotated, err := time.Parse(time.RFC3339, pod.ObjectMeta.GetAnnotations()[k8s.EvictAtAnnotation])
+	if err != nil {
+		return false
+	}
+	drift := evictAt.Sub(annotated)
+	return drift > -noticeTolerance && drift < noticeTolerance
+}
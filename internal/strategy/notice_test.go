@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNoticeEviction(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		remaining   time.Duration
+		annotations map[string]string
+		dryRun      bool
+		paused      bool
+		noticed     bool
+	}
+
+	evictAt := time.Now().Add(10 * time.Minute)
+	data := map[string]unitData{
+		"pod expiring within the notice": {
+			remaining: 10 * time.Minute,
+			noticed:   true,
+		},
+		"pod expiring after the notice": {
+			remaining: 20 * time.Minute,
+		},
+		"pod already noticed": {
+			remaining:   10 * time.Minute,
+			annotations: map[string]string{k8s.EvictAtAnnotation: evictAt.UTC().Format(time.RFC3339)},
+		},
+		"pod noticed of another date": {
+			remaining:   10 * time.Minute,
+			annotations: map[string]string{k8s.EvictAtAnnotation: evictAt.Add(time.Hour).UTC().Format(time.RFC3339)},
+			noticed:     true,
+		},
+		"pod opted out": {
+			remaining:   10 * time.Minute,
+			annotations: map[string]string{k8s.SkipAnnotation: "true"},
+		},
+		"pod in paused namespace": {
+			remaining: 10 * time.Minute,
+			paused:    true,
+		},
+		"dry-run": {
+			remaining: 10 * time.Minute,
+			dryRun:    true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "namespace-1",
+					Annotations: unit.annotations}}
+				k8sMock.On("Paused", "namespace-1").Return(unit.paused, "namespace annotated").Maybe()
+				if unit.noticed {
+					k8sMock.On("AnnotateEvictAt", ctx, "namespace-1", "pod-1", mock.MatchedBy(func(at time.Time) bool {
+						return at.Sub(evictAt) < time.Minute && evictAt.Sub(at) < time.Minute
+					})).Return(nil).Once()
+					k8sMock.On("EmitEvent", ctx, k8s.PodReference("namespace-1", "pod-1"), k8s.EventTypeWarning,
+						eventReasonEvictionScheduled, mock.Anything).Return(nil).Once()
+				}
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					EvictionNotice: 15 * time.Minute,
+					DryRun:         unit.dryRun,
+				}, k8sMock)
+				d.noticeEviction(ctx, pod, unit.remaining, logrus.Fields{})
+
+				k8sMock.AssertExpectations(t)
+				if !unit.noticed {
+					k8sMock.AssertNotCalled(t, "AnnotateEvictAt", ctx, "namespace-1", "pod-1", mock.Anything)
+				}
+			}
+		}(unit))
+	}
+}
@@ -3,6 +3,7 @@ package strategy
 import (
 	"context"
 	"math/rand"
+	"net/http"
 	"time"
 
 	"github.com/backmarket-oss/raccoon/internal"
@@ -12,16 +13,57 @@ import (
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type k8sClient interface {
-	ListPods(ctx context.Context, namespace, labelSelector string) ([]v1.Pod, error)
-	EvictPod(ctx context.Context, namespace, name string) error
+	ListPods(ctx context.Context, namespace, labelSelector, fieldSelector string) ([]v1.Pod, error)
+	GetPod(ctx context.Context, namespace, name string) (*v1.Pod, error)
+	EvictPod(ctx context.Context, namespace, name string, uid types.UID) error
+	DeletePod(ctx context.Context, namespace, name string, uid types.UID, gracePeriod time.Duration) error
+	OwnerFromPod(ctx context.Context, pod v1.Pod) (*k8s.Owner, error)
+	RestartWorkload(ctx context.Context, owner k8s.Owner) error
+	WorkloadReplicas(ctx context.Context, owner k8s.Owner) (desired, ready int32, err error)
+	EmitEvent(ctx context.Context, involved v1.ObjectReference, eventType, reason, message string) error
+	Paused(namespace string) (bool, string)
+	PausedNamespaces() []string
+	ResolveExpiration(ctx context.Context, pod v1.Pod, defaultTTL time.Duration) (k8s.Expiration, error)
+	NodeZone(ctx context.Context, nodeName string) (string, error)
+	SurgeWorkload(ctx context.Context, owner k8s.Owner) (k8s.Surge, error)
+	RestoreSurge(ctx context.Context, surge k8s.Surge) error
+	WorkloadUnstable(ctx context.Context, owner k8s.Owner) (string, error)
+	SetDrainGate(ctx context.Context, namespace, name string, open bool) error
+	AnnotateEvictAt(ctx context.Context, namespace, name string, at time.Time) error
+	StaleTemplate(ctx context.Context, pod v1.Pod) (bool, error)
+	ChangedConfig(ctx context.Context, pod v1.Pod) (*k8s.Owner, error)
+	PodsWorkingSet(ctx context.Context, namespace, labelSelector string) (map[types.NamespacedName]map[string]int64, error)
 }
 
 type namespacedPod struct {
 	name      string
 	namespace string
+	// uid identifies the pod as it was when marked,
+	// the pod may have been recreated with the same name before being collected.
+	uid types.UID
+	// version is the pod's resource version when marked, a pod changed since then is checked again before being
+	// collected.
+	version string
+	// owner is the workload owning the pod, nil for pods without a supported owner.
+	owner *k8s.Owner
+	// restart is set when the owner must be restarted instead of evicting the pod.
+	restart bool
+	// criterion the pod is collected for, e.g. its ttl, its stale template or its changed config.
+	criterion string
+	// expiredAt is when the pod went past its ttl, zero when collected for another criterion.
+	expiredAt time.Time
+	// node and zone the pod runs in, the zone is only resolved when collection is topology aware.
+	node string
+	zone string
+	// drainGate is set when the pod declares the drain readiness gate, it is drained before being evicted.
+	drainGate bool
+	// preEvictHook is the url called before evicting the pod, empty when the pod doesn't declare one.
+	preEvictHook string
 }
 
 type RandomizedDelay struct {
@@ -30,80 +72,287 @@ type RandomizedDelay struct {
 	collector       chan *namespacedPod
 	randomizer      *rand.Rand
 	k8sClient       k8sClient
+	restarts        *cooldown
+	breaker         *breaker
+	retries         *evictionRetries
+	// spacing remembers the nodes and zones where pods have been collected during the topology window.
+	spacing *cooldown
+	drains  *drains
+	// hookClient calls the pods' pre-evict hooks.
+	hookClient *http.Client
+	memory     *memoryPressure
 }
 
 var (
 	podsDeleted = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "raccoon_pods_deleted_total",
-			Help: "The total number of deleted pods",
+			Help: "The total number of deleted pods, by criterion they have been collected for",
+		},
+		[]string{"namespace", "criterion"})
+	podsSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_pods_skipped_total",
+			Help: "The total number of pods to collect which haven't been, by reason, e.g. opted-out, bare-pod, paused, " +
+				"min-ready-replicas or disruption-budget, and recreated, bare-pod, selector-mismatch or not-expired " +
+				"when checked again before being collected",
+		},
+		[]string{"namespace", "reason"})
+	pausedGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raccoon_paused",
+			Help: "Whether raccoon is paused by a kill switch, namespace is empty for a cluster-wide pause",
 		},
 		[]string{"namespace"})
+	workloadsRestarted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_workloads_restarted_total",
+			Help: "The total number of restarted workloads",
+		},
+		[]string{"namespace", "kind"})
 )
 
 // InitRandomizedDelay initializes RandomizedDelay struct.
 func InitRandomizedDelay(ctx context.Context, maxDelay int,
 	dSettings *internal.DefaultSettings, k8sClient k8sClient) *RandomizedDelay {
 
+	delay := newRandomizedDelay(maxDelay, dSettings, k8sClient)
+
+	go delay.collectEventLoop(ctx)
+
+	return delay
+}
+
+func newRandomizedDelay(maxDelay int, dSettings *internal.DefaultSettings, k8sClient k8sClient) *RandomizedDelay {
 	rndSource := rand.NewSource(time.Now().UnixNano())
-	delay := &RandomizedDelay{
+	return &RandomizedDelay{
 		defaultSettings: dSettings,
 		maxDelay:        maxDelay,
 		collector:       make(chan *namespacedPod),
 		randomizer:      rand.New(rndSource),
 		k8sClient:       k8sClient,
+		restarts:        newCooldown(dSettings.RestartCooldown),
+		breaker: newBreaker(dSettings.BreakerFailures, dSettings.BreakerDeadline,
+			dSettings.BreakerCooldown),
+		retries:    newEvictionRetries(dSettings.EvictionBackoff),
+		spacing:    newCooldown(dSettings.TopologyWindow),
+		drains:     newDrains(),
+		hookClient: newHookClient(),
+		memory:     newMemoryPressure(),
 	}
-
-	go delay.collectEventLoop(ctx)
-
-	return delay
 }
 
 // Run requests k8s api to retrieve pods with an age older than the ttl.
 // It sends pods' name to an internal channel.
 // The sending action isn't blocking.
-func (d RandomizedDelay) Run(ctx context.Context) error {
-	return findPodsToCollect(ctx, d.k8sClient, d.defaultSettings.Namespace,
-		d.defaultSettings.Selector, d.defaultSettings.TTL, d.collector)
+func (d *RandomizedDelay) Run(ctx context.Context) error {
+	return d.findPodsToCollect(ctx)
 }
 
-func findPodsToCollect(ctx context.Context, k8sClient k8sClient,
-	namespace, selector string, defaultTTL time.Duration, collector chan *namespacedPod) error {
-	pods, err := k8sClient.ListPods(ctx, namespace, selector)
+func (d *RandomizedDelay) findPodsToCollect(ctx context.Context) error {
+	d.checkReplacements(ctx)
+
+	pods, err := d.listPods(ctx)
 	if err != nil {
 		return err
 	}
+	// gated pods aren't ready until their drain gate is open, even when raccoon is paused
+	d.openDrainGates(ctx, pods)
+
+	if paused, reason := d.exposePause(); paused {
+		log.WithField("reason", reason).Info("raccoon paused, skipping check")
+		return nil
+	}
+
+	listed := make(map[types.UID]bool, len(pods))
 	for _, pod := range pods {
-		configuredTTL, err := k8s.TTLFromPod(pod, defaultTTL)
-		if err != nil {
-			return err
-		}
-		nsPod := &namespacedPod{
-			name:      pod.ObjectMeta.Name,
-			namespace: pod.ObjectMeta.Namespace,
-		}
+		listed[pod.ObjectMeta.UID] = true
+	}
+	d.retries.prune(listed)
 
-		tDiff := k8s.DateFromPodInSecond(pod)
-		lFields := logrus.Fields{
-			"namespace": nsPod.namespace,
-			"selector":  selector,
-			"pod":       pod.ObjectMeta.Name,
-			"age":       tDiff,
-		}
-		log.WithFields(lFields).Debug("checking pod's age")
-
-		if tDiff > configuredTTL.Seconds() {
-			select {
-			case collector <- nsPod:
-				log.WithFields(lFields).Info("pod's age greater than ttl, marking pod")
-			case <-ctx.Done():
-			}
-		}
+	if d.defaultSettings.MemoryLimitRatio > 0 {
+		d.trackMemory(ctx, pods)
 	}
 
+	cycle := &markingCycle{
+		markedOwners:   make(map[string]bool),
+		unstableOwners: make(map[string]string),
+		gate:           d.evaluateHealth(ctx, pods),
+		overdue:        make(map[string]int),
+		maxOverdue:     make(map[string]time.Duration),
+	}
+	for _, pod := range d.orderPods(ctx, pods, cycle) {
+		d.checkPod(ctx, pod, cycle)
+	}
+
+	podsOverdue.Reset()
+	podsOverdueSeconds.Reset()
+	for namespace, count := range cycle.overdue {
+		podsOverdue.With(prometheus.Labels{"namespace": namespace}).Set(float64(count))
+		podsOverdueSeconds.With(prometheus.Labels{"namespace": namespace}).Set(cycle.maxOverdue[namespace].Seconds())
+	}
 	return nil
 }
 
+// exposePause sets the paused gauge from the kill switches, whether pods are checked in the paused namespaces or not.
+// It reports whether raccoon is paused cluster-wide, along with the reason.
+func (d *RandomizedDelay) exposePause() (bool, string) {
+	pausedGauge.Reset()
+	paused, reason := d.k8sClient.Paused("")
+	pausedGauge.With(prometheus.Labels{"namespace": ""}).Set(boolToFloat(paused))
+	for _, namespace := range d.k8sClient.PausedNamespaces() {
+		pausedGauge.With(prometheus.Labels{"namespace": namespace}).Set(1)
+	}
+	return paused, reason
+}
+
+// orderPods orders the pods to check, by descending score when weighted, then spread across zones when
+// topology aware. With rollout-restart, the pods are kept ordered by age, so the owner of the oldest pod is
+// restarted first and an owner is marked by its oldest pod.
+func (d *RandomizedDelay) orderPods(ctx context.Context, pods []v1.Pod, cycle *markingCycle) []v1.Pod {
+	if d.topologyAware() {
+		cycle.zones = d.resolveZones(ctx, pods)
+		cycle.zoneDisruptions = zoneDisruptions(pods, cycle.zones)
+	}
+	if d.defaultSettings.Action == internal.ActionRolloutRestart {
+		return pods
+	}
+	if d.defaultSettings.Score.Enabled() {
+		pods = d.sortByScore(ctx, pods)
+	}
+	if d.topologyAware() {
+		pods = spreadByTopology(pods, cycle.zones)
+	}
+	return pods
+}
+
+// markingCycle holds the state shared by the pods checked during one run.
+type markingCycle struct {
+	// with rollout-restart, pods are checked by age, so the first pod seen for an owner is its oldest one
+	markedOwners map[string]bool
+	// unstableOwners keeps why owners aren't stable, an empty reason for stable ones
+	unstableOwners map[string]string
+	gate           healthGate
+	// overdue counts, per namespace, the pods past their ttl, maxOverdue is the longest time past it.
+	overdue    map[string]int
+	maxOverdue map[string]time.Duration
+	// zones of the nodes running the pods, and number of disrupted pods per zone, when topology aware
+	zones           map[string]string
+	zoneDisruptions map[string]int
+}
+
+// checkPod sends the pod to the collector when it is older than its ttl.
+// A pod whose ttl can't be resolved is skipped, so a single bad annotation doesn't stop the collection.
+func (d *RandomizedDelay) checkPod(ctx context.Context, pod v1.Pod, cycle *markingCycle) {
+	nsPod := &namespacedPod{
+		name:      pod.ObjectMeta.Name,
+		namespace: pod.ObjectMeta.Namespace,
+		uid:       pod.ObjectMeta.UID,
+		node:      pod.Spec.NodeName,
+		version:   pod.ObjectMeta.ResourceVersion,
+		zone:      cycle.zones[pod.Spec.NodeName],
+		drainGate: k8s.HasDrainGate(pod),
+	}
+
+	lFields := logrus.Fields{
+		"namespace": nsPod.namespace,
+		"selector":  d.defaultSettings.Selector,
+		"pod":       pod.ObjectMeta.Name,
+	}
+	expiration, err := d.k8sClient.ResolveExpiration(ctx, pod, d.defaultSettings.TTL)
+	if err != nil {
+		lFields["error"] = err.Error()
+		skipPod(nsPod, skipReasonUnresolvedTTL, lFields)
+		return
+	}
+	age := expiration.Age(pod, d.defaultSettings.AgeSource, time.Now())
+	lFields["age"] = age.Seconds()
+	log.WithFields(lFields).Debug("checking pod's age")
+
+	nsPod.criterion = d.criterion(ctx, pod, expiration, age, lFields)
+	if nsPod.criterion == "" {
+		d.noticeEviction(ctx, pod, expiration.Remaining(pod, age, time.Now()), lFields)
+		return
+	}
+	lFields["criterion"] = nsPod.criterion
+	if nsPod.criterion == criterionTTL {
+		overdue := expiration.Overdue(pod, age, time.Now())
+		nsPod.expiredAt = time.Now().Add(-overdue)
+		cycle.recordOverdue(nsPod.namespace, overdue)
+	}
+	reason, err := d.skipReason(ctx, checkedPod{pod: pod, nsPod: nsPod, cycle: cycle, lFields: lFields})
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while checking pod, skipping pod: %v", err)
+		return
+	}
+	if reason != "" {
+		skipPod(nsPod, reason, lFields)
+		return
+	}
+	if !d.paced(nsPod, cycle, lFields) {
+		return
+	}
+
+	select {
+	case d.collector <- nsPod:
+		log.WithFields(lFields).Info("pod must be collected, marking pod")
+	case <-ctx.Done():
+	}
+}
+
+// recordOverdue counts a pod past its ttl in its namespace.
+func (c *markingCycle) recordOverdue(namespace string, overdue time.Duration) {
+	c.overdue[namespace]++
+	if overdue > c.maxOverdue[namespace] {
+		c.maxOverdue[namespace] = overdue
+	}
+}
+
+// paced reports whether the pod can be collected now: with rollout-restart its owner is marked once per check,
+// otherwise evictions are spaced out and kept within the zone budget.
+func (d *RandomizedDelay) paced(nsPod *namespacedPod, cycle *markingCycle, lFields logrus.Fields) bool {
+	if d.defaultSettings.Action == internal.ActionRolloutRestart && !d.markRestart(nsPod, cycle.markedOwners, lFields) {
+		return false
+	}
+	if nsPod.restart {
+		return true
+	}
+	if !d.spacedOut(nsPod, lFields) || !d.withinZoneBudget(nsPod, cycle, lFields) {
+		return false
+	}
+	if nsPod.zone != "" {
+		cycle.zoneDisruptions[nsPod.zone]++
+	}
+	return true
+}
+
+// skipPod logs and counts a pod older than its ttl which isn't collected.
+func skipPod(nsPod *namespacedPod, reason string, lFields logrus.Fields) {
+	log.WithFields(lFields).WithField("reason", reason).Info("pod can't be collected, skipping pod")
+	podsSkipped.With(prometheus.Labels{"namespace": nsPod.namespace, "reason": reason}).Inc()
+}
+
+// markRestart marks the pod's owner to be restarted, it reports whether the pod must be sent to the collector.
+// The pod is skipped when its owner has already been marked during this check,
+// or has been restarted during the cooldown period.
+// Pods without a restartable owner are evicted instead.
+func (d *RandomizedDelay) markRestart(nsPod *namespacedPod, markedOwners map[string]bool,
+	lFields logrus.Fields) bool {
+	if nsPod.owner == nil {
+		log.WithFields(lFields).Debug("pod has no restartable owner, falling back to eviction")
+		return true
+	}
+
+	key := nsPod.owner.String()
+	if markedOwners[key] || d.restarts.active(key, time.Now()) {
+		log.WithFields(lFields).WithField("owner", key).Debug("owner already restarted, skipping pod")
+		return false
+	}
+	markedOwners[key] = true
+	nsPod.restart = true
+	return true
+}
+
 // collect listen to the internal channel for pods to delete.
 // This is where it applies the randomized delay between consecutive deletion.
 func (d *RandomizedDelay) collectEventLoop(ctx context.Context) {
@@ -112,7 +361,7 @@ func (d *RandomizedDelay) collectEventLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case markedPod := <-d.collector:
-			collectMarkedPod(ctx, d.defaultSettings.DryRun, d.k8sClient, *markedPod)
+			d.collectMarkedPod(ctx, *markedPod)
 			/*
 			   here we apply a randomized delay before going to the next iteration.
 			   we don't want to use 0 as minimum value to avoid too short period between deletion.
@@ -128,27 +377,94 @@ func (d *RandomizedDelay) collectEventLoop(ctx context.Context) {
 	}
 }
 
-func collectMarkedPod(ctx context.Context, isDryRun bool,
-	k8sClient k8sClient, markedPod namespacedPod) {
+func (d *RandomizedDelay) collectMarkedPod(ctx context.Context, markedPod namespacedPod) {
 	lFields := logrus.Fields{
 		"pod":       markedPod.name,
 		"namespace": markedPod.namespace,
 	}
 	log.WithFields(lFields).Debug("new pod to collect")
 
-	if !isDryRun {
-		err := k8sClient.EvictPod(ctx, markedPod.namespace, markedPod.name)
-		if err != nil {
-			log.WithFields(lFields).Errorf("error while deleting pod: %v", err)
-		} else {
-			log.WithFields(lFields).Info("pod deleted")
-			podsDeleted.With(prometheus.Labels{"namespace": markedPod.namespace}).Inc()
-		}
+	// the kill switch may have been set since the pod has been marked
+	if paused, reason := d.k8sClient.Paused(markedPod.namespace); paused {
+		lFields["pauseReason"] = reason
+		skipPod(&markedPod, skipReasonPaused, lFields)
+		return
+	}
+	// so may have been the pod's annotations
+	pod, err := d.k8sClient.GetPod(ctx, markedPod.namespace, markedPod.name)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while getting marked pod, skipping pod: %v", err)
+		return
+	}
+	if optedOut, reason := k8s.OptedOut(*pod, time.Now()); optedOut {
+		skipPod(&markedPod, reason, lFields)
+		return
+	}
+	if !d.stillCollectable(ctx, markedPod, *pod, lFields) {
+		return
+	}
+
+	if markedPod.restart {
+		d.restartOwner(ctx, *markedPod.owner, lFields)
+		return
+	}
+	// pods collected since marking may have started the window of the pod's node or zone
+	if !d.spacedOut(&markedPod, lFields) {
+		return
+	}
+	if d.surgeable(ctx, markedPod, *pod, lFields) {
+		d.surgeAndEvict(ctx, markedPod, lFields)
+		return
+	}
+	if !d.hasEnoughReadyReplicas(ctx, markedPod, *pod, lFields) {
+		return
+	}
+
+	if !d.defaultSettings.DryRun {
+		d.evict(ctx, markedPod, lFields)
 	} else {
 		log.WithFields(lFields).Debug("dry-run, pod should have been deleted")
 	}
 }
 
+// evict evicts the marked pod, evictions refused by a disruption budget are retried later.
+// Pods declaring the drain gate are drained first, and opened again when not evicted.
+func (d *RandomizedDelay) evict(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	if markedPod.drainGate {
+		if !d.drain(ctx, markedPod, lFields) {
+			return
+		}
+		defer d.drains.stop(markedPod.uid)
+	}
+	if markedPod.preEvictHook != "" {
+		d.callPreEvictHook(ctx, markedPod, lFields)
+	}
+
+	err := d.k8sClient.EvictPod(ctx, markedPod.namespace, markedPod.name, markedPod.uid)
+	if err != nil && markedPod.drainGate {
+		d.undrain(ctx, markedPod, lFields)
+	}
+	if apierrors.IsTooManyRequests(err) {
+		d.evictionBlocked(ctx, markedPod, lFields)
+	} else if err != nil {
+		log.WithFields(lFields).Errorf("error while deleting pod: %v", err)
+	} else {
+		log.WithFields(lFields).Info("pod deleted")
+		d.collected(ctx, markedPod, lFields)
+	}
+}
+
+// collected records a deleted pod.
+func (d *RandomizedDelay) collected(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	podsDeleted.With(prometheus.Labels{"namespace": markedPod.namespace, "criterion": markedPod.criterion}).Inc()
+	d.emitCollected(ctx, markedPod, lFields)
+	d.retries.forget(markedPod.uid)
+	d.recordTopology(markedPod, time.Now())
+	if markedPod.owner != nil {
+		d.breaker.recordEviction(*markedPod.owner, time.Now())
+	}
+}
+
 func waitRandomizedDelay(ctx context.Context, delay int) {
 	select {
 	case <-time.After(time.Duration(delay) * time.Second):
@@ -157,3 +473,24 @@ func waitRandomizedDelay(ctx context.Context, delay int) {
 		return
 	}
 }
+
+func (d *RandomizedDelay) restartOwner(ctx context.Context, owner k8s.Owner, lFields logrus.Fields) {
+	lFields["owner"] = owner.String()
+	if d.defaultSettings.DryRun {
+		log.WithFields(lFields).Debug("dry-run, workload should have been restarted")
+		return
+	}
+	if d.restarts.active(owner.String(), time.Now()) {
+		log.WithFields(lFields).Debug("workload restarted during cooldown period, skipping")
+		return
+	}
+
+	err := d.k8sClient.RestartWorkload(ctx, owner)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while restarting workload: %v", err)
+		return
+	}
+	d.restarts.record(owner.String(), time.Now())
+	log.WithFields(lFields).Info("workload restarted")
+	workloadsRestarted.With(prometheus.Labels{"namespace": owner.Namespace, "kind": owner.Kind}).Inc()
+}
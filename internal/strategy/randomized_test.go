@@ -2,30 +2,149 @@ package strategy
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type K8sClientMock struct {
 	mock.Mock
 }
 
-func (m *K8sClientMock) ListPods(ctx context.Context, namespace, labelSelector string) ([]v1.Pod, error) {
+func (m *K8sClientMock) ListPods(ctx context.Context, namespace, labelSelector, fieldSelector string) ([]v1.Pod,
+	error) {
+	args := m.Called(ctx, namespace, labelSelector, fieldSelector)
+	return args.Get(0).([]v1.Pod), args.Error(1)
+}
+
+func (m *K8sClientMock) EvictPod(ctx context.Context, namespace, name string, uid types.UID) error {
+	args := m.Called(ctx, namespace, name, uid)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) DeletePod(ctx context.Context, namespace, name string, uid types.UID,
+	gracePeriod time.Duration) error {
+	args := m.Called(ctx, namespace, name, uid, gracePeriod)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) NodeZone(ctx context.Context, nodeName string) (string, error) {
+	args := m.Called(ctx, nodeName)
+	return args.String(0), args.Error(1)
+}
+
+func (m *K8sClientMock) SurgeWorkload(ctx context.Context, owner k8s.Owner) (k8s.Surge, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).(k8s.Surge), args.Error(1)
+}
+
+func (m *K8sClientMock) RestoreSurge(ctx context.Context, surge k8s.Surge) error {
+	args := m.Called(ctx, surge)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) WorkloadUnstable(ctx context.Context, owner k8s.Owner) (string, error) {
+	args := m.Called(ctx, owner)
+	return args.String(0), args.Error(1)
+}
+
+func (m *K8sClientMock) SetDrainGate(ctx context.Context, namespace, name string, open bool) error {
+	args := m.Called(ctx, namespace, name, open)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) StaleTemplate(ctx context.Context, pod v1.Pod) (bool, error) {
+	args := m.Called(ctx, pod)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *K8sClientMock) ChangedConfig(ctx context.Context, pod v1.Pod) (*k8s.Owner, error) {
+	args := m.Called(ctx, pod)
+	return args.Get(0).(*k8s.Owner), args.Error(1)
+}
+
+func (m *K8sClientMock) PodsWorkingSet(ctx context.Context, namespace,
+	labelSelector string) (map[types.NamespacedName]map[string]int64, error) {
 	args := m.Called(ctx, namespace, labelSelector)
+	return args.Get(0).(map[types.NamespacedName]map[string]int64), args.Error(1)
+}
+
+func (m *K8sClientMock) ListNodes(ctx context.Context, labelSelector string) ([]v1.Node, error) {
+	args := m.Called(ctx, labelSelector)
+	return args.Get(0).([]v1.Node), args.Error(1)
+}
+
+func (m *K8sClientMock) CordonNode(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) NodePods(ctx context.Context, name string) ([]v1.Pod, error) {
+	args := m.Called(ctx, name)
 	return args.Get(0).([]v1.Pod), args.Error(1)
 }
 
-func (m *K8sClientMock) EvictPod(ctx context.Context, namespace, name string) error {
-	args := m.Called(ctx, namespace, name)
+func (m *K8sClientMock) DeleteNode(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) AnnotateEvictAt(ctx context.Context, namespace, name string, at time.Time) error {
+	args := m.Called(ctx, namespace, name, at)
 	return args.Error(0)
 }
 
+func (m *K8sClientMock) OwnerFromPod(ctx context.Context, pod v1.Pod) (*k8s.Owner, error) {
+	args := m.Called(ctx, pod)
+	return args.Get(0).(*k8s.Owner), args.Error(1)
+}
+
+func (m *K8sClientMock) RestartWorkload(ctx context.Context, owner k8s.Owner) error {
+	args := m.Called(ctx, owner)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) ResolveExpiration(ctx context.Context, pod v1.Pod,
+	defaultTTL time.Duration) (k8s.Expiration, error) {
+	args := m.Called(ctx, pod, defaultTTL)
+	return args.Get(0).(k8s.Expiration), args.Error(1)
+}
+
+func (m *K8sClientMock) WorkloadReplicas(ctx context.Context, owner k8s.Owner) (int32, int32, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).(int32), args.Get(1).(int32), args.Error(2)
+}
+
+func (m *K8sClientMock) EmitEvent(ctx context.Context, involved v1.ObjectReference,
+	eventType, reason, message string) error {
+	args := m.Called(ctx, involved, eventType, reason, message)
+	return args.Error(0)
+}
+
+func (m *K8sClientMock) Paused(namespace string) (bool, string) {
+	args := m.Called(namespace)
+	return args.Bool(0), args.String(1)
+}
+
+func (m *K8sClientMock) PausedNamespaces() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *K8sClientMock) GetPod(ctx context.Context, namespace, name string) (*v1.Pod, error) {
+	args := m.Called(ctx, namespace, name)
+	return args.Get(0).(*v1.Pod), args.Error(1)
+}
+
 func TestFindPodsToCollect(t *testing.T) {
 	t.Parallel()
 
@@ -120,7 +239,12 @@ func TestFindPodsToCollect(t *testing.T) {
 				ctx := context.Background()
 				collector := make(chan *namespacedPod)
 
-				k8sMock.On("ListPods", ctx, unit.namespace, unit.selector).Return(unit.pods, nil)
+				k8sMock.On("Paused", mock.Anything).Return(false, "")
+				k8sMock.On("PausedNamespaces").Return([]string(nil))
+				k8sMock.On("ListPods", ctx, unit.namespace, unit.selector, "").Return(unit.pods, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, unit.defaultTTL).
+					Return(k8s.Expiration{TTL: unit.defaultTTL}, nil)
+				k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil).Maybe()
 
 				//synchronization primitive to make sure channel has finished its work
 				wgClosed := new(sync.WaitGroup)
@@ -146,7 +270,14 @@ func TestFindPodsToCollect(t *testing.T) {
 					wgClosed.Done()
 				}()
 
-				err := findPodsToCollect(ctx, k8sMock, unit.namespace, unit.selector, unit.defaultTTL, collector)
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					Namespace: unit.namespace,
+					Selector:  unit.selector,
+					TTL:       unit.defaultTTL,
+					Action:    internal.ActionEvict,
+				}, k8sMock)
+				d.collector = collector
+				err := d.findPodsToCollect(ctx)
 				close(collector)
 
 				wgClosed.Wait()
@@ -176,6 +307,7 @@ func TestCollect(t *testing.T) {
 			markedPod: namespacedPod{
 				name:      "pod-1",
 				namespace: "namespace-1",
+				uid:       "uid-1",
 			},
 		},
 		"dry run deactivated": {
@@ -183,6 +315,7 @@ func TestCollect(t *testing.T) {
 			markedPod: namespacedPod{
 				name:      "pod-2",
 				namespace: "namespace-1",
+				uid:       "uid-2",
 			},
 		},
 	}
@@ -193,12 +326,175 @@ func TestCollect(t *testing.T) {
 				k8sMock := new(K8sClientMock)
 				ctx := context.Background()
 
+				k8sMock.On("Paused", unit.markedPod.namespace).Return(false, "")
+				k8sMock.On("GetPod", ctx, unit.markedPod.namespace, unit.markedPod.name).Return(&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:               unit.markedPod.uid,
+						CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+					},
+				}, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
 				if !unit.dryRun {
-					k8sMock.On("EvictPod", ctx, unit.markedPod.namespace, unit.markedPod.name).Return(nil)
+					k8sMock.On("EvictPod", ctx, unit.markedPod.namespace, unit.markedPod.name, unit.markedPod.uid).
+						Return(nil)
 				}
-				collectMarkedPod(ctx, unit.dryRun, k8sMock, unit.markedPod)
+				d := newRandomizedDelay(0, &internal.DefaultSettings{DryRun: unit.dryRun}, k8sMock)
+				d.collectMarkedPod(ctx, unit.markedPod)
 				k8sMock.AssertExpectations(t)
 			}
 		}(unit))
 	}
 }
+
+func TestRolloutRestart(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	k8sMock := new(K8sClientMock)
+	ctx := context.Background()
+	collector := make(chan *namespacedPod, 10)
+	owner := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+
+	oldest := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:              "pod-1",
+		Namespace:         "namespace-1",
+		Labels:            map[string]string{"app": "app-1"},
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+	}}
+	older := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:              "pod-2",
+		Namespace:         "namespace-1",
+		Labels:            map[string]string{"app": "app-1"},
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-90 * time.Minute)),
+	}}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "namespace-1", "app=app-1", "").Return([]v1.Pod{oldest, older}, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return(owner, nil)
+	k8sMock.On("RestartWorkload", ctx, *owner).Return(nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Namespace:       "namespace-1",
+		Selector:        "app=app-1",
+		TTL:             time.Hour,
+		Action:          internal.ActionRolloutRestart,
+		RestartCooldown: time.Hour,
+	}, k8sMock)
+	d.collector = collector
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	// only the oldest pod of the owner is marked
+	assert.Len(collector, 1)
+	marked := <-collector
+	assert.Equal("pod-1", marked.name)
+	assert.Equal(owner, marked.owner)
+
+	k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(&oldest, nil)
+	d.collectMarkedPod(ctx, *marked)
+
+	// the owner is in its cooldown period, nothing is marked anymore
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(collector, 0)
+	k8sMock.AssertExpectations(t)
+}
+
+func TestPaused(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	pods := []v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{
+			Name:              "pod-1",
+			Namespace:         "namespace-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}},
+		{ObjectMeta: metav1.ObjectMeta{
+			Name:              "pod-2",
+			Namespace:         "namespace-2",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}},
+	}
+	settings := &internal.DefaultSettings{Selector: "app=app-1", TTL: time.Hour, Action: internal.ActionEvict}
+
+	// cluster-wide pause
+	k8sMock := new(K8sClientMock)
+	k8sMock.On("Paused", "").Return(true, "configmap raccoon/raccoon-pause")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	d := newRandomizedDelay(0, settings, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 0)
+	k8sMock.AssertExpectations(t)
+
+	// namespace pause
+	k8sMock = new(K8sClientMock)
+	k8sMock.On("Paused", "").Return(false, "")
+	k8sMock.On("Paused", "namespace-1").Return(true, "namespace annotated")
+	k8sMock.On("Paused", "namespace-2").Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string{"namespace-1"})
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil)
+	d = newRandomizedDelay(0, settings, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 1)
+	assert.Equal("pod-2", (<-d.collector).name)
+
+	// pause set between marking and collection
+	k8sMock = new(K8sClientMock)
+	k8sMock.On("Paused", "namespace-2").Return(true, "namespace annotated")
+	d = newRandomizedDelay(0, settings, k8sMock)
+	d.collectMarkedPod(ctx, namespacedPod{name: "pod-2", namespace: "namespace-2"})
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "EvictPod", ctx, "namespace-2", "pod-2", mock.Anything)
+}
+
+func TestFindPodsToCollectUnresolvedTTL(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	pods := []v1.Pod{ownedPod("pod-1", k8s.KindReplicaSet), ownedPod("pod-2", k8s.KindReplicaSet),
+		ownedPod("pod-3", k8s.KindReplicaSet)}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	// pod-2's owner has a malformed ttl annotation
+	k8sMock.On("ResolveExpiration", ctx, pods[1], time.Hour).Return(k8s.Expiration{},
+		errors.New(`invalid ttl on ReplicaSet namespace-1/owner-1: time: invalid duration "forever"`))
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{Selector: "app=app-1", TTL: time.Hour}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 2)
+	assert.Equal("pod-1", (<-d.collector).name)
+	assert.Equal("pod-3", (<-d.collector).name)
+}
+
+func TestCollectOptedOutPod(t *testing.T) {
+	t.Parallel()
+
+	k8sMock := new(K8sClientMock)
+	ctx := context.Background()
+	snoozed := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pod-1",
+		Namespace:   "namespace-1",
+		Annotations: map[string]string{k8s.SnoozeUntilAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)},
+	}}
+	k8sMock.On("Paused", "namespace-1").Return(false, "")
+	k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(snoozed, nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{}, k8sMock)
+	d.collectMarkedPod(ctx, namespacedPod{name: "pod-1", namespace: "namespace-1"})
+
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "EvictPod", ctx, "namespace-1", "pod-1", mock.Anything)
+}
@@ -0,0 +1,173 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	skipReasonDisruptionBudget = "disruption-budget"
+	skipReasonEvictionBackoff  = "eviction-backoff"
+	eventReasonEvictionOverdue = "RaccoonEvictionOverdue"
+
+	// maxEvictionBackoff caps the delay between two attempts to evict a blocked pod.
+	maxEvictionBackoff = time.Hour
+)
+
+var (
+	podsOverdue = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raccoon_pods_overdue",
+			Help: "The number of pods older than their ttl which haven't been collected yet, at the last check",
+		},
+		[]string{"namespace"})
+	podsOverdueSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raccoon_pods_overdue_seconds",
+			Help: "The longest duration a pod has been past its ttl without being collected, at the last check",
+		},
+		[]string{"namespace"})
+	evictionsEscalated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_evictions_escalated_total",
+			Help: "The total number of blocked evictions escalated after the max lateness, by action taken",
+		},
+		[]string{"namespace", "action"})
+)
+
+// blockedEviction is a pod whose eviction has been refused, usually by a disruption budget.
+type blockedEviction struct {
+	attempts    int
+	nextAttempt time.Time
+	escalated   bool
+}
+
+// evictionRetries retries blocked evictions with an exponential backoff, instead of at every check.
+type evictionRetries struct {
+	mu      sync.Mutex
+	backoff time.Duration
+	pods    map[types.UID]*blockedEviction
+}
+
+func newEvictionRetries(backoff time.Duration) *evictionRetries {
+	return &evictionRetries{
+		backoff: backoff,
+		pods:    make(map[types.UID]*blockedEviction),
+	}
+}
+
+// blocked records a refused eviction, it returns the number of attempts and when the next one is allowed.
+// The delay doubles at each attempt, up to maxEvictionBackoff.
+func (r *evictionRetries) blocked(uid types.UID, now time.Time) (int, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.pods[uid]
+	if !ok {
+		b = &blockedEviction{}
+		r.pods[uid] = b
+	}
+	b.attempts++
+	delay := r.backoff
+	for i := 1; i < b.attempts && delay < maxEvictionBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxEvictionBackoff {
+		delay = maxEvictionBackoff
+	}
+	b.nextAttempt = now.Add(delay)
+	return b.attempts, b.nextAttempt
+}
+
+// waiting reports whether the pod's eviction has been refused and must not be retried yet.
+func (r *evictionRetries) waiting(uid types.UID, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.pods[uid]
+	return ok && now.Before(b.nextAttempt)
+}
+
+// escalate reports whether the pod's blocked eviction hasn't been escalated yet, and marks it escalated.
+func (r *evictionRetries) escalate(uid types.UID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.pods[uid]
+	if !ok || b.escalated {
+		return false
+	}
+	b.escalated = true
+	return true
+}
+
+// forget stops tracking a pod, once collected.
+func (r *evictionRetries) forget(uid types.UID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pods, uid)
+}
+
+// prune stops tracking the pods which aren't listed anymore.
+func (r *evictionRetries) prune(listed map[types.UID]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for uid := range r.pods {
+		if !listed[uid] {
+			delete(r.pods, uid)
+		}
+	}
+}
+
+// evictionBlocked schedules the retry of a refused eviction.
+// Once the pod is overdue for longer than the max lateness, the eviction is escalated.
+func (d *RandomizedDelay) evictionBlocked(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	now := time.Now()
+	attempts, nextAttempt := d.retries.blocked(markedPod.uid, now)
+	lFields["attempts"] = attempts
+	lFields["nextAttempt"] = nextAttempt.Format(time.RFC3339)
+	skipPod(&markedPod, skipReasonDisruptionBudget, lFields)
+
+	maxLateness := d.defaultSettings.MaxLateness
+	if maxLateness <= 0 || markedPod.expiredAt.IsZero() || now.Sub(markedPod.expiredAt) <= maxLateness {
+		return
+	}
+	d.escalate(ctx, markedPod, now.Sub(markedPod.expiredAt), lFields)
+}
+
+// escalate warns about a pod stuck past the max lateness, once, and deletes it when allowed,
+// bypassing its disruption budget.
+func (d *RandomizedDelay) escalate(ctx context.Context, markedPod namespacedPod, overdue time.Duration,
+	lFields logrus.Fields) {
+	lFields["overdue"] = overdue.Seconds()
+	if d.retries.escalate(markedPod.uid) {
+		log.WithFields(lFields).Warn("pod's eviction blocked past the max lateness, escalating")
+		evictionsEscalated.With(prometheus.Labels{"namespace": markedPod.namespace, "action": "event"}).Inc()
+		message := fmt.Sprintf("pod is %v past its ttl and its eviction is still blocked by a disruption budget",
+			overdue.Truncate(time.Second))
+		err := d.k8sClient.EmitEvent(ctx, k8s.PodReference(markedPod.namespace, markedPod.name),
+			k8s.EventTypeWarning, eventReasonEvictionOverdue, message)
+		if err != nil {
+			log.WithFields(lFields).Errorf("error while emitting event: %v", err)
+		}
+	}
+	if !d.defaultSettings.EscalationDelete {
+		return
+	}
+
+	err := d.k8sClient.DeletePod(ctx, markedPod.namespace, markedPod.name, markedPod.uid,
+		d.defaultSettings.EscalationGracePeriod)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while deleting overdue pod: %v", err)
+		return
+	}
+	log.WithFields(lFields).Warn("overdue pod deleted")
+	evictionsEscalated.With(prometheus.Labels{"namespace": markedPod.namespace, "action": "delete"}).Inc()
+	d.collected(ctx, markedPod, lFields)
+}
@@ -0,0 +1,115 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEvictionBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	now := time.Now()
+	retries := newEvictionRetries(10 * time.Minute)
+
+	assert.False(retries.waiting("uid-1", now))
+	expected := []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute, time.Hour, time.Hour}
+	for i, delay := range expected {
+		attempts, next := retries.blocked("uid-1", now)
+		assert.Equal(i+1, attempts)
+		assert.Equal(now.Add(delay), next)
+	}
+	assert.True(retries.waiting("uid-1", now))
+	assert.False(retries.waiting("uid-1", now.Add(time.Hour)))
+
+	retries.prune(map[types.UID]bool{"uid-2": true})
+	assert.False(retries.waiting("uid-1", now))
+}
+
+func TestBlockedEviction(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		maxLateness      time.Duration
+		escalationDelete bool
+		expiredAt        time.Time
+		escalated        bool
+	}
+
+	data := map[string]unitData{
+		"pod overdue within the max lateness": {
+			maxLateness: 24 * time.Hour,
+			expiredAt:   time.Now().Add(-time.Hour),
+		},
+		"pod overdue past the max lateness": {
+			maxLateness: 24 * time.Hour,
+			expiredAt:   time.Now().Add(-48 * time.Hour),
+			escalated:   true,
+		},
+		"pod overdue past the max lateness, deletion enabled": {
+			maxLateness:      24 * time.Hour,
+			escalationDelete: true,
+			expiredAt:        time.Now().Add(-48 * time.Hour),
+			escalated:        true,
+		},
+		"escalation disabled": {
+			expiredAt: time.Now().Add(-48 * time.Hour),
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				assert := assert.New(t)
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1",
+					expiredAt: unit.expiredAt}
+				pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+					Name:              "pod-1",
+					Namespace:         "namespace-1",
+					UID:               "uid-1",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+				}}
+
+				k8sMock.On("Paused", mock.Anything).Return(false, "")
+				k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(pod, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+				k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1")).
+					Return(apierrors.NewTooManyRequests("disruption budget", 10)).Once()
+				if unit.escalated {
+					k8sMock.On("EmitEvent", ctx, k8s.PodReference("namespace-1", "pod-1"), k8s.EventTypeWarning,
+						eventReasonEvictionOverdue, mock.Anything).Return(nil).Once()
+				}
+				if unit.escalationDelete {
+					k8sMock.On("DeletePod", ctx, "namespace-1", "pod-1", types.UID("uid-1"), 30*time.Second).
+						Return(nil).Once()
+				}
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					EvictionBackoff:       5 * time.Minute,
+					MaxLateness:           unit.maxLateness,
+					EscalationDelete:      unit.escalationDelete,
+					EscalationGracePeriod: 30 * time.Second,
+				}, k8sMock)
+				d.collectMarkedPod(ctx, markedPod)
+
+				// the pod is not marked again before the backoff is over, unless deleted on escalation
+				assert.Equal(!unit.escalationDelete, d.retries.waiting("uid-1", time.Now()))
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
@@ -0,0 +1,200 @@
+package strategy
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// priorityScale is the priority at which the priority criterion is halved.
+	priorityScale = 1000
+)
+
+// scoredPod is a pod along with what its collection score is computed from.
+type scoredPod struct {
+	pod        v1.Pod
+	expiration k8s.Expiration
+	age        time.Duration
+	ownerSize  int32
+	score      float64
+	// unresolved is set when the pod's ttl can't be resolved, the pod isn't scored.
+	unresolved bool
+}
+
+// PlannedPod is a pod matching the selector, as seen by the collection.
+type PlannedPod struct {
+	Namespace string
+	Name      string
+	Age       time.Duration
+	TTL       time.Duration
+	Expired   bool
+	Priority  int32
+	QoS       v1.PodQOSClass
+	Restarts  int32
+	Score     float64
+	// Skipped is why the pod isn't collected when expired, empty when it is.
+	Skipped string
+}
+
+// Plan lists the pods matching the settings in the order they would be collected,
+// expired pods first and by descending score, without collecting them.
+func Plan(ctx context.Context, dSettings *internal.DefaultSettings, k8sClient k8sClient) ([]PlannedPod, error) {
+	d := newRandomizedDelay(0, dSettings, k8sClient)
+	pods, err := d.listPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scored := d.scorePods(ctx, pods)
+
+	now := time.Now()
+	planned := make([]PlannedPod, 0, len(scored))
+	for _, s := range scored {
+		planned = append(planned, PlannedPod{
+			Namespace: s.pod.ObjectMeta.Namespace,
+			Name:      s.pod.ObjectMeta.Name,
+			Age:       s.age,
+			TTL:       s.expiration.TTL,
+			Expired:   !s.unresolved && s.expiration.Expired(s.pod, s.age, now),
+			Priority:  podPriority(s.pod),
+			QoS:       s.pod.Status.QOSClass,
+			Restarts:  podRestarts(s.pod),
+			Score:     s.score,
+			Skipped:   d.skipped(s, now),
+		})
+	}
+	sort.SliceStable(planned, func(i, j int) bool {
+		if planned[i].Expired != planned[j].Expired {
+			return planned[i].Expired
+		}
+		return planned[i].Score > planned[j].Score
+	})
+	return planned, nil
+}
+
+// skipped returns why the pod is skipped, because of its unresolved ttl, opted out or excluded from collection,
+// empty when it isn't.
+func (d *RandomizedDelay) skipped(s scoredPod, now time.Time) string {
+	if s.unresolved {
+		return skipReasonUnresolvedTTL
+	}
+	if optedOut, reason := k8s.OptedOut(s.pod, now); optedOut {
+		return reason
+	}
+	return d.excluded(s.pod)
+}
+
+// sortByScore orders pods by descending score, pods with the same score keep their order.
+func (d *RandomizedDelay) sortByScore(ctx context.Context, pods []v1.Pod) []v1.Pod {
+	scored := d.scorePods(ctx, pods)
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	sorted := make([]v1.Pod, 0, len(scored))
+	for _, s := range scored {
+		sorted = append(sorted, s.pod)
+	}
+	return sorted
+}
+
+// scorePods computes the collection score of each pod.
+// Owners' size is only looked up when weighted. Pods whose ttl can't be resolved are scored 0,
+// they are skipped when checked.
+func (d *RandomizedDelay) scorePods(ctx context.Context, pods []v1.Pod) []scoredPod {
+	weights := d.defaultSettings.Score
+	ownerSizes := make(map[string]int32)
+	scored := make([]scoredPod, 0, len(pods))
+	for _, pod := range pods {
+		expiration, err := d.k8sClient.ResolveExpiration(ctx, pod, d.defaultSettings.TTL)
+		s := scoredPod{
+			pod: pod,
+			age: expiration.Age(pod, d.defaultSettings.AgeSource, time.Now()),
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{"namespace": pod.ObjectMeta.Namespace, "pod": pod.ObjectMeta.Name}).
+				Errorf("error while resolving pod's ttl, not scoring pod: %v", err)
+			s.unresolved = true
+			scored = append(scored, s)
+			continue
+		}
+		s.expiration = expiration
+		if weights.OwnerSize > 0 {
+			s.ownerSize = d.ownerSize(ctx, pod, ownerSizes)
+		}
+		s.score = score(s, weights)
+		scored = append(scored, s)
+	}
+	return scored
+}
+
+// ownerSize returns the desired replicas of the pod's owner, 0 when it can't be resolved.
+func (d *RandomizedDelay) ownerSize(ctx context.Context, pod v1.Pod, sizes map[string]int32) int32 {
+	owner, err := d.k8sClient.OwnerFromPod(ctx, pod)
+	if err != nil || owner == nil {
+		return 0
+	}
+	if size, ok := sizes[owner.String()]; ok {
+		return size
+	}
+	desired, _, err := d.k8sClient.WorkloadReplicas(ctx, *owner)
+	if err != nil {
+		log.WithField("owner", owner.String()).Errorf("error while getting workload's replicas: %v", err)
+	}
+	sizes[owner.String()] = desired
+	return desired
+}
+
+// score combines the weighted criteria, each of them but the age being between 0 and 1.
+func score(s scoredPod, weights internal.ScoreWeights) float64 {
+	ageRatio := 1.0
+	if s.expiration.TTL > 0 {
+		ageRatio = float64(s.age) / float64(s.expiration.TTL)
+	}
+
+	priority := podPriority(s.pod)
+	if priority < 0 {
+		priority = 0
+	}
+	priorityScore := 1 / (1 + float64(priority)/priorityScale)
+
+	var qosScore float64
+	switch s.pod.Status.QOSClass {
+	case v1.PodQOSBestEffort:
+		qosScore = 1
+	case v1.PodQOSBurstable, "":
+		qosScore = 0.5
+	}
+
+	restarts := float64(podRestarts(s.pod))
+	restartsScore := restarts / (1 + restarts)
+
+	var ownerSizeScore float64
+	if s.ownerSize > 0 {
+		ownerSizeScore = 1 - 1/float64(s.ownerSize)
+	}
+
+	return weights.Age*ageRatio + weights.Priority*priorityScore + weights.QoS*qosScore +
+		weights.Restarts*restartsScore + weights.OwnerSize*ownerSizeScore
+}
+
+func podPriority(pod v1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+// podRestarts sums the restarts of the pod's containers.
+func podRestarts(pod v1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
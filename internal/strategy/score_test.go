@@ -0,0 +1,220 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func scoringPod(name string, priority int32, qos v1.PodQOSClass, restarts int32) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "namespace-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		},
+		Spec: v1.PodSpec{Priority: &priority},
+		Status: v1.PodStatus{
+			QOSClass:          qos,
+			ContainerStatuses: []v1.ContainerStatus{{RestartCount: restarts}},
+		},
+	}
+}
+
+func TestScore(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		scored   scoredPod
+		weights  internal.ScoreWeights
+		expected float64
+	}
+
+	data := map[string]unitData{
+		"age over ttl": {
+			scored:   scoredPod{age: 3 * time.Hour, expiration: k8s.Expiration{TTL: 2 * time.Hour}},
+			weights:  internal.ScoreWeights{Age: 2},
+			expected: 3,
+		},
+		"no ttl": {
+			scored:   scoredPod{age: 3 * time.Hour},
+			weights:  internal.ScoreWeights{Age: 1},
+			expected: 1,
+		},
+		"default priority": {
+			scored:   scoredPod{pod: scoringPod("pod-1", 0, v1.PodQOSGuaranteed, 0)},
+			weights:  internal.ScoreWeights{Priority: 1},
+			expected: 1,
+		},
+		"high priority": {
+			scored:   scoredPod{pod: scoringPod("pod-1", 1000, v1.PodQOSGuaranteed, 0)},
+			weights:  internal.ScoreWeights{Priority: 1},
+			expected: 0.5,
+		},
+		"best effort": {
+			scored:   scoredPod{pod: scoringPod("pod-1", 0, v1.PodQOSBestEffort, 0)},
+			weights:  internal.ScoreWeights{QoS: 1},
+			expected: 1,
+		},
+		"guaranteed": {
+			scored:   scoredPod{pod: scoringPod("pod-1", 0, v1.PodQOSGuaranteed, 0)},
+			weights:  internal.ScoreWeights{QoS: 1},
+			expected: 0,
+		},
+		"restarts": {
+			scored:   scoredPod{pod: scoringPod("pod-1", 0, v1.PodQOSGuaranteed, 3)},
+			weights:  internal.ScoreWeights{Restarts: 1},
+			expected: 0.75,
+		},
+		"owner size": {
+			scored:   scoredPod{ownerSize: 4},
+			weights:  internal.ScoreWeights{OwnerSize: 1},
+			expected: 0.75,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+				assert.InDelta(t, unit.expected, score(unit.scored, unit.weights), 0.001)
+			}
+		}(unit))
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	critical := scoringPod("critical", 1000000, v1.PodQOSGuaranteed, 0)
+	burstable := scoringPod("burstable", 0, v1.PodQOSBurstable, 0)
+	bestEffort := scoringPod("best-effort", 0, v1.PodQOSBestEffort, 0)
+	recent := scoringPod("recent", 0, v1.PodQOSBestEffort, 0)
+	recent.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Now())
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return([]v1.Pod{critical, burstable, recent, bestEffort}, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+
+	planned, err := Plan(ctx, &internal.DefaultSettings{
+		Selector: "app=app-1",
+		TTL:      time.Hour,
+		Score:    internal.ScoreWeights{Priority: 1, QoS: 1},
+	}, k8sMock)
+	assert.Nil(err)
+
+	var names []string
+	for _, p := range planned {
+		names = append(names, p.Name)
+	}
+	// pods which aren't expired come last, whatever their score
+	assert.Equal([]string{"best-effort", "burstable", "critical", "recent"}, names)
+	assert.True(planned[0].Expired)
+	assert.False(planned[3].Expired)
+	k8sMock.AssertExpectations(t)
+}
+
+func TestFindPodsToCollectByScore(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	guaranteed := scoringPod("guaranteed", 0, v1.PodQOSGuaranteed, 0)
+	bestEffort := scoringPod("best-effort", 0, v1.PodQOSBestEffort, 0)
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return([]v1.Pod{guaranteed, bestEffort}, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector: "app=app-1",
+		TTL:      time.Hour,
+		Score:    internal.ScoreWeights{QoS: 1},
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	assert.Len(d.collector, 2)
+	assert.Equal("best-effort", (<-d.collector).name)
+	assert.Equal("guaranteed", (<-d.collector).name)
+	k8sMock.AssertExpectations(t)
+}
+
+func TestScoreUnresolvedTTL(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	broken := scoringPod("broken", 0, v1.PodQOSBestEffort, 0)
+	guaranteed := scoringPod("guaranteed", 0, v1.PodQOSGuaranteed, 0)
+	bestEffort := scoringPod("best-effort", 0, v1.PodQOSBestEffort, 0)
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return([]v1.Pod{broken, guaranteed, bestEffort}, nil)
+	k8sMock.On("ResolveExpiration", ctx, broken, time.Hour).Return(k8s.Expiration{},
+		errors.New(`invalid ttl on namespace namespace-1: time: invalid duration "forever"`))
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	settings := &internal.DefaultSettings{
+		Selector: "app=app-1",
+		TTL:      time.Hour,
+		Score:    internal.ScoreWeights{QoS: 1},
+	}
+
+	d := newRandomizedDelay(0, settings, k8sMock)
+	var names []string
+	for _, pod := range d.sortByScore(ctx, []v1.Pod{broken, guaranteed, bestEffort}) {
+		names = append(names, pod.ObjectMeta.Name)
+	}
+	// the pod whose ttl can't be resolved is kept, scored 0
+	assert.Equal([]string{"best-effort", "broken", "guaranteed"}, names)
+
+	planned, err := Plan(ctx, settings, k8sMock)
+	assert.Nil(err)
+	assert.Len(planned, 3)
+	for _, p := range planned {
+		if p.Name == "broken" {
+			assert.Equal(skipReasonUnresolvedTTL, p.Skipped)
+			assert.False(p.Expired)
+		}
+	}
+}
+
+func TestRolloutRestartKeepsAgeOrder(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	owner := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	oldest := scoringPod("oldest", 0, v1.PodQOSGuaranteed, 0)
+	oldest.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Now().Add(-3 * time.Hour))
+	bestEffort := scoringPod("best-effort", 0, v1.PodQOSBestEffort, 0)
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return([]v1.Pod{oldest, bestEffort}, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return(owner, nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector: "app=app-1",
+		TTL:      time.Hour,
+		Action:   internal.ActionRolloutRestart,
+		Score:    internal.ScoreWeights{QoS: 1},
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	// the score would check the BestEffort pod first, the owner is marked by its oldest pod instead
+	assert.Len(d.collector, 1)
+	assert.Equal("oldest", (<-d.collector).name)
+}
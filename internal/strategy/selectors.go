@@ -0,0 +1,42 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// listPods lists the pods matching the selectors, leaving out the ones matching the exclusion selectors.
+func (d *RandomizedDelay) listPods(ctx context.Context) ([]v1.Pod, error) {
+	s := d.defaultSettings
+	pods, err := d.k8sClient.ListPods(ctx, s.Namespace, s.Selector, s.FieldSelector)
+	if err != nil || (s.ExcludeSelector == "" && s.ExcludeFieldSelector == "") {
+		return pods, err
+	}
+	selected := make([]v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		excluded, err := k8s.MatchesSelectors(pod, s.ExcludeSelector, s.ExcludeFieldSelector)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			selected = append(selected, pod)
+		}
+	}
+	return selected, nil
+}
+
+// selected reports whether the pod matches the selectors and doesn't match the exclusion selectors.
+func (d *RandomizedDelay) selected(pod v1.Pod) (bool, error) {
+	s := d.defaultSettings
+	matches, err := k8s.MatchesSelectors(pod, s.Selector, s.FieldSelector)
+	if err != nil || !matches {
+		return false, err
+	}
+	if s.ExcludeSelector == "" && s.ExcludeFieldSelector == "" {
+		return true, nil
+	}
+	excluded, err := k8s.MatchesSelectors(pod, s.ExcludeSelector, s.ExcludeFieldSelector)
+	return !excluded, err
+}
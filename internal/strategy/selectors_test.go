@@ -0,0 +1,97 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func selectorPod(name, tier, node string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace-1",
+			Labels: map[string]string{"app": "app-1", "tier": tier}},
+		Spec: v1.PodSpec{NodeName: node},
+	}
+}
+
+func TestListPodsExclusionSelectors(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		excludeSelector      string
+		excludeFieldSelector string
+		expectedNames        []string
+	}
+
+	data := map[string]unitData{
+		"no exclusion":            {expectedNames: []string{"web-spot", "db-spot", "db-ondemand"}},
+		"labels only":             {excludeSelector: "tier=db", expectedNames: []string{"web-spot"}},
+		"fields only":             {excludeFieldSelector: "spec.nodeName=spot-1", expectedNames: []string{"db-ondemand"}},
+		"db pods on spot nodes":   {excludeSelector: "tier=db", excludeFieldSelector: "spec.nodeName=spot-1", expectedNames: []string{"web-spot", "db-ondemand"}},
+		"set based exclusion":     {excludeSelector: "tier in (web,db)", expectedNames: []string{}},
+		"exclusion matching none": {excludeSelector: "tier=cache", expectedNames: []string{"web-spot", "db-spot", "db-ondemand"}},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				ctx := context.Background()
+				k8sMock := new(K8sClientMock)
+				k8sMock.On("ListPods", ctx, "namespace-1", "app=app-1", "status.phase=Running").Return([]v1.Pod{
+					selectorPod("web-spot", "web", "spot-1"),
+					selectorPod("db-spot", "db", "spot-1"),
+					selectorPod("db-ondemand", "db", "ondemand-1"),
+				}, nil)
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					Namespace:            "namespace-1",
+					Selector:             "app=app-1",
+					FieldSelector:        "status.phase=Running",
+					ExcludeSelector:      unit.excludeSelector,
+					ExcludeFieldSelector: unit.excludeFieldSelector,
+				}, k8sMock)
+				pods, err := d.listPods(ctx)
+
+				assert.Nil(t, err)
+				names := []string{}
+				for _, pod := range pods {
+					names = append(names, pod.ObjectMeta.Name)
+				}
+				assert.Equal(t, unit.expectedNames, names)
+				k8sMock.AssertExpectations(t)
+			}
+		}(unit))
+	}
+}
+
+func TestSelected(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector:             "app=app-1",
+		FieldSelector:        "spec.nodeName!=",
+		ExcludeSelector:      "tier=db",
+		ExcludeFieldSelector: "spec.nodeName=spot-1",
+	}, new(K8sClientMock))
+
+	for _, unit := range []struct {
+		pod      v1.Pod
+		expected bool
+	}{
+		{pod: selectorPod("web-spot", "web", "spot-1"), expected: true},
+		{pod: selectorPod("db-spot", "db", "spot-1"), expected: false},
+		{pod: selectorPod("db-ondemand", "db", "ondemand-1"), expected: true},
+		{pod: selectorPod("web-pending", "web", ""), expected: false},
+	} {
+		selected, err := d.selected(unit.pod)
+		assert.Nil(err)
+		assert.Equal(unit.expected, selected, unit.pod.ObjectMeta.Name)
+	}
+}
@@ -0,0 +1,111 @@
+package strategy
+
+import (
+	"context"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	skipReasonSurgeTimeout = "surge-timeout"
+
+	surgePollInterval = 5 * time.Second
+	// restoreTimeout bounds the restoration of a surged Deployment, done even once the daemon is stopping.
+	restoreTimeout = 30 * time.Second
+)
+
+var (
+	surges = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raccoon_surges_total",
+			Help: "The total number of Deployments scaled up before evicting one of their pods, by result",
+		},
+		[]string{"namespace", "result"})
+)
+
+// surgeable reports whether the marked pod's Deployment is small enough to be scaled up before evicting the pod.
+// Pods which aren't ready, as got right before evicting them, don't lower the Deployment's capacity,
+// they are evicted right away.
+func (d *RandomizedDelay) surgeable(ctx context.Context, markedPod namespacedPod, pod v1.Pod,
+	lFields logrus.Fields) bool {
+	maxReplicas := int32(d.defaultSettings.SurgeMaxReplicas)
+	if maxReplicas <= 0 || markedPod.owner == nil || markedPod.owner.Kind != k8s.KindDeployment ||
+		!k8s.IsPodReady(pod) {
+		return false
+	}
+	desired, _, err := d.k8sClient.WorkloadReplicas(ctx, *markedPod.owner)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while getting workload's replicas, not surging: %v", err)
+		return false
+	}
+	return desired <= maxReplicas
+}
+
+// surgeAndEvict scales the pod's Deployment up by one replica, waits for the extra pod to be ready,
+// evicts the pod and restores the Deployment's replicas.
+// The pod is skipped when the extra pod isn't ready before the surge timeout.
+func (d *RandomizedDelay) surgeAndEvict(ctx context.Context, markedPod namespacedPod, lFields logrus.Fields) {
+	lFields["owner"] = markedPod.owner.String()
+	if d.defaultSettings.DryRun {
+		log.WithFields(lFields).Debug("dry-run, deployment should have been surged and pod deleted")
+		return
+	}
+
+	surge, err := d.k8sClient.SurgeWorkload(ctx, *markedPod.owner)
+	if err != nil {
+		log.WithFields(lFields).Errorf("error while surging deployment, skipping pod: %v", err)
+		return
+	}
+	lFields["hpa"] = surge.HPA
+	log.WithFields(lFields).WithField("target", surge.Target).Info("deployment surged")
+	defer func() {
+		restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+		defer cancel()
+		if err := d.k8sClient.RestoreSurge(restoreCtx, surge); err != nil {
+			log.WithFields(lFields).Errorf("error while restoring surged deployment: %v", err)
+			return
+		}
+		log.WithFields(lFields).Info("surged deployment restored")
+	}()
+
+	if !d.waitSurge(ctx, surge, lFields) {
+		surges.With(prometheus.Labels{"namespace": markedPod.namespace, "result": "timeout"}).Inc()
+		skipPod(&markedPod, skipReasonSurgeTimeout, lFields)
+		return
+	}
+	surges.With(prometheus.Labels{"namespace": markedPod.namespace, "result": "ready"}).Inc()
+	d.evict(ctx, markedPod, lFields)
+}
+
+// waitSurge reports whether the surged Deployment reaches its target ready replicas before the surge timeout.
+func (d *RandomizedDelay) waitSurge(ctx context.Context, surge k8s.Surge, lFields logrus.Fields) bool {
+	deadline := time.Now().Add(d.defaultSettings.SurgeTimeout)
+	for {
+		_, ready, err := d.k8sClient.WorkloadReplicas(ctx, surge.Owner)
+		if err != nil {
+			log.WithFields(lFields).Errorf("error while getting surged deployment's replicas: %v", err)
+		} else if ready >= surge.Target {
+			return true
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		wait := surgePollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
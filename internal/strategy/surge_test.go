@@ -0,0 +1,137 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// readyPod is the marked pod, ready, as got right before collecting it.
+func readyPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:               "uid-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}},
+	}
+}
+
+func TestSurgeBeforeEvict(t *testing.T) {
+	t.Parallel()
+
+	type unitData struct {
+		desired  int32
+		ready    int32
+		notReady bool
+		surged   bool
+		evicted  bool
+	}
+
+	data := map[string]unitData{
+		"small deployment, extra pod ready": {
+			desired: 2,
+			ready:   3,
+			surged:  true,
+			evicted: true,
+		},
+		"small deployment, extra pod not ready in time": {
+			desired: 2,
+			ready:   2,
+			surged:  true,
+		},
+		"small deployment, pod not ready anymore": {
+			desired:  2,
+			ready:    1,
+			notReady: true,
+			evicted:  true,
+		},
+		"large deployment": {
+			desired: 5,
+			ready:   5,
+			evicted: true,
+		},
+	}
+
+	for name, unit := range data {
+		t.Run(name, func(unit unitData) func(t *testing.T) {
+			return func(t *testing.T) {
+				t.Parallel()
+
+				k8sMock := new(K8sClientMock)
+				ctx := context.Background()
+				owner := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+				markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1", owner: owner}
+				pod := readyPod()
+				if unit.notReady {
+					pod.Status.Conditions = nil
+				}
+				surge := k8s.Surge{Owner: *owner, Target: 3, Original: k8s.SurgeOriginal{Replicas: 2}}
+
+				k8sMock.On("Paused", "namespace-1").Return(false, "")
+				k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(pod, nil)
+				k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+				if !unit.notReady {
+					k8sMock.On("WorkloadReplicas", ctx, *owner).Return(unit.desired, unit.ready, nil)
+				}
+				if unit.surged {
+					k8sMock.On("SurgeWorkload", ctx, *owner).Return(surge, nil).Once()
+					k8sMock.On("RestoreSurge", mock.Anything, surge).Return(nil).Once()
+				}
+				if unit.evicted {
+					k8sMock.On("EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1")).Return(nil).Once()
+				}
+
+				d := newRandomizedDelay(0, &internal.DefaultSettings{
+					SurgeMaxReplicas: 3,
+					SurgeTimeout:     10 * time.Millisecond,
+				}, k8sMock)
+				d.collectMarkedPod(ctx, markedPod)
+
+				k8sMock.AssertExpectations(t)
+				if !unit.evicted {
+					k8sMock.AssertNotCalled(t, "EvictPod", ctx, "namespace-1", "pod-1", types.UID("uid-1"))
+				}
+			}
+		}(unit))
+	}
+}
+
+func TestSurgeRestoredOnceCancelled(t *testing.T) {
+	t.Parallel()
+
+	k8sMock := new(K8sClientMock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	owner := &k8s.Owner{Kind: k8s.KindDeployment, Namespace: "namespace-1", Name: "app-1"}
+	markedPod := namespacedPod{name: "pod-1", namespace: "namespace-1", uid: "uid-1", owner: owner}
+	surge := k8s.Surge{Owner: *owner, Target: 3, Original: k8s.SurgeOriginal{Replicas: 2}}
+
+	k8sMock.On("Paused", "namespace-1").Return(false, "")
+	k8sMock.On("GetPod", ctx, "namespace-1", "pod-1").Return(readyPod(), nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Duration(0)).Return(k8s.Expiration{}, nil)
+	k8sMock.On("WorkloadReplicas", ctx, *owner).Return(int32(2), int32(2), nil).Once()
+	k8sMock.On("SurgeWorkload", ctx, *owner).Return(surge, nil).Once()
+	// the daemon is stopped while the extra pod isn't ready yet
+	k8sMock.On("WorkloadReplicas", ctx, *owner).Return(int32(3), int32(2), nil).
+		Run(func(mock.Arguments) { cancel() })
+	k8sMock.On("RestoreSurge", mock.MatchedBy(func(restoreCtx context.Context) bool {
+		return restoreCtx.Err() == nil
+	}), surge).Return(nil).Once()
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		SurgeMaxReplicas: 3,
+		SurgeTimeout:     time.Minute,
+	}, k8sMock)
+	d.collectMarkedPod(ctx, markedPod)
+
+	k8sMock.AssertExpectations(t)
+	k8sMock.AssertNotCalled(t, "EvictPod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,123 @@
+package strategy
+
+import (
+	"context"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	skipReasonTopologySpacing = "topology-spacing"
+	skipReasonZoneDisruptions = "zone-disruptions"
+)
+
+func (d *RandomizedDelay) topologyAware() bool {
+	return d.defaultSettings.TopologyWindow > 0 || d.defaultSettings.MaxZoneDisruptions > 0
+}
+
+// resolveZones returns the zone of each node running one of the pods.
+// Nodes whose zone can't be resolved are left out.
+func (d *RandomizedDelay) resolveZones(ctx context.Context, pods []v1.Pod) map[string]string {
+	zones := make(map[string]string)
+	for _, pod := range pods {
+		node := pod.Spec.NodeName
+		if _, ok := zones[node]; ok || node == "" {
+			continue
+		}
+		zone, err := d.k8sClient.NodeZone(ctx, node)
+		if err != nil {
+			log.WithField("node", node).Errorf("error while resolving node's zone: %v", err)
+			continue
+		}
+		zones[node] = zone
+	}
+	return zones
+}
+
+// spreadByTopology orders pods so that consecutive ones run in different zones:
+// zones are taken in turn, starting with the one of the oldest pod, and pods keep their age order within a zone.
+func spreadByTopology(pods []v1.Pod, zones map[string]string) []v1.Pod {
+	var order []string
+	byZone := make(map[string][]v1.Pod)
+	for _, pod := range pods {
+		zone := zones[pod.Spec.NodeName]
+		if _, ok := byZone[zone]; !ok {
+			order = append(order, zone)
+		}
+		byZone[zone] = append(byZone[zone], pod)
+	}
+
+	spread := make([]v1.Pod, 0, len(pods))
+	for len(spread) < len(pods) {
+		for _, zone := range order {
+			if len(byZone[zone]) > 0 {
+				spread = append(spread, byZone[zone][0])
+				byZone[zone] = byZone[zone][1:]
+			}
+		}
+	}
+	return spread
+}
+
+// zoneDisruptions counts, per zone, the pods currently disrupted: not ready or being deleted.
+// Completed pods are left out.
+func zoneDisruptions(pods []v1.Pod, zones map[string]string) map[string]int {
+	disruptions := make(map[string]int)
+	for _, pod := range pods {
+		zone, ok := zones[pod.Spec.NodeName]
+		if !ok || zone == "" || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if pod.ObjectMeta.DeletionTimestamp != nil || !k8s.IsPodReady(pod) {
+			disruptions[zone]++
+		}
+	}
+	return disruptions
+}
+
+// spacedOut reports whether no pod has been collected on the pod's node, nor in its zone, during the topology window.
+func (d *RandomizedDelay) spacedOut(nsPod *namespacedPod, lFields logrus.Fields) bool {
+	now := time.Now()
+	if (nsPod.node != "" && d.spacing.active(nodeKey(nsPod.node), now)) ||
+		(nsPod.zone != "" && d.spacing.active(zoneKey(nsPod.zone), now)) {
+		skipPod(nsPod, skipReasonTopologySpacing, lFields)
+		return false
+	}
+	return true
+}
+
+// withinZoneBudget reports whether one more pod can be disrupted in the pod's zone.
+func (d *RandomizedDelay) withinZoneBudget(nsPod *namespacedPod, cycle *markingCycle, lFields logrus.Fields) bool {
+	maxDisruptions := d.defaultSettings.MaxZoneDisruptions
+	if maxDisruptions <= 0 || nsPod.zone == "" {
+		return true
+	}
+	if cycle.zoneDisruptions[nsPod.zone] >= maxDisruptions {
+		lFields["zoneDisruptions"] = cycle.zoneDisruptions[nsPod.zone]
+		skipPod(nsPod, skipReasonZoneDisruptions, lFields)
+		return false
+	}
+	return true
+}
+
+// recordTopology starts the topology window of the collected pod's node and zone.
+func (d *RandomizedDelay) recordTopology(markedPod namespacedPod, now time.Time) {
+	if markedPod.node != "" {
+		d.spacing.record(nodeKey(markedPod.node), now)
+	}
+	if markedPod.zone != "" {
+		d.spacing.record(zoneKey(markedPod.zone), now)
+	}
+}
+
+func nodeKey(node string) string {
+	return "node/" + node
+}
+
+func zoneKey(zone string) string {
+	return "zone/" + zone
+}
@@ -0,0 +1,124 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/backmarket-oss/raccoon/internal"
+	"github.com/backmarket-oss/raccoon/internal/k8s"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func podOnNode(name, node string, age time.Duration, ready bool) v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "namespace-1",
+			UID:               types.UID("uid-" + name),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: v1.PodSpec{NodeName: node},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestSpreadByTopology(t *testing.T) {
+	t.Parallel()
+
+	zones := map[string]string{"node-a1": "a", "node-a2": "a", "node-b1": "b"}
+	pods := []v1.Pod{
+		podOnNode("pod-1", "node-a1", 5*time.Hour, true),
+		podOnNode("pod-2", "node-a2", 4*time.Hour, true),
+		podOnNode("pod-3", "node-a1", 3*time.Hour, true),
+		podOnNode("pod-4", "node-b1", 2*time.Hour, true),
+		podOnNode("pod-5", "", time.Hour, true),
+	}
+
+	var names []string
+	for _, pod := range spreadByTopology(pods, zones) {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"pod-1", "pod-4", "pod-5", "pod-2", "pod-3"}, names)
+}
+
+func TestZoneDisruptions(t *testing.T) {
+	t.Parallel()
+
+	zones := map[string]string{"node-a1": "a", "node-b1": "b"}
+	terminating := podOnNode("pod-3", "node-b1", time.Hour, true)
+	terminating.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	completed := podOnNode("pod-4", "node-b1", time.Hour, false)
+	completed.Status.Phase = v1.PodSucceeded
+	pods := []v1.Pod{
+		podOnNode("pod-1", "node-a1", time.Hour, true),
+		podOnNode("pod-2", "node-a1", time.Hour, false),
+		terminating,
+		completed,
+		podOnNode("pod-5", "", time.Hour, false),
+	}
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, zoneDisruptions(pods, zones))
+}
+
+func TestTopologyAwareMarking(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	k8sMock := new(K8sClientMock)
+	pods := []v1.Pod{
+		podOnNode("pod-1", "node-a1", 5*time.Hour, true),
+		podOnNode("pod-2", "node-a2", 4*time.Hour, true),
+		podOnNode("pod-3", "node-b1", 3*time.Hour, true),
+		podOnNode("pod-4", "node-c1", 2*time.Hour, true),
+		podOnNode("pod-5", "node-c2", 2*time.Hour, false),
+	}
+	k8sMock.On("Paused", mock.Anything).Return(false, "")
+	k8sMock.On("PausedNamespaces").Return([]string(nil))
+	k8sMock.On("ListPods", ctx, "", "app=app-1", "").Return(pods, nil)
+	k8sMock.On("ResolveExpiration", ctx, mock.Anything, time.Hour).Return(k8s.Expiration{TTL: time.Hour}, nil)
+	k8sMock.On("OwnerFromPod", ctx, mock.Anything).Return((*k8s.Owner)(nil), nil)
+	k8sMock.On("NodeZone", ctx, "node-a1").Return("a", nil)
+	k8sMock.On("NodeZone", ctx, "node-a2").Return("a", nil)
+	k8sMock.On("NodeZone", ctx, "node-b1").Return("b", nil)
+	k8sMock.On("NodeZone", ctx, "node-c1").Return("c", nil)
+	k8sMock.On("NodeZone", ctx, "node-c2").Return("c", nil)
+
+	d := newRandomizedDelay(0, &internal.DefaultSettings{
+		Selector:           "app=app-1",
+		TTL:                time.Hour,
+		TopologyWindow:     10 * time.Minute,
+		MaxZoneDisruptions: 1,
+	}, k8sMock)
+	d.collector = make(chan *namespacedPod, 10)
+	// a pod has just been collected in zone b
+	d.recordTopology(namespacedPod{node: "node-b1", zone: "b"}, time.Now())
+
+	assert.Nil(d.findPodsToCollect(ctx))
+	// zone a: one pod marked, the second one would exceed the zone's disruptions
+	// zone b: within the topology window
+	// zone c: a pod is already not ready
+	assert.Len(d.collector, 1)
+	marked := <-d.collector
+	assert.Equal("pod-1", marked.name)
+	assert.Equal("a", marked.zone)
+
+	// the window of zone a starts once its pod is collected
+	d.recordTopology(*marked, time.Now())
+	assert.False(d.spacedOut(&namespacedPod{node: "node-a2", zone: "a"}, logrus.Fields{}))
+	assert.True(d.spacedOut(&namespacedPod{node: "node-d1", zone: "d"}, logrus.Fields{}))
+	k8sMock.AssertExpectations(t)
+}